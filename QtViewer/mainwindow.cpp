@@ -5,6 +5,8 @@
 #include <QPixmap>
 #include <QSqlQuery>
 #include <QIODevice>
+#include <QJsonDocument>
+#include <QJsonObject>
 #include <QLabel>
 
 #include <algorithm>
@@ -14,6 +16,19 @@
 
 constexpr int GenerationFrequency = 100;
 const QString socketName = "generation.sock";
+constexpr int SupportedSchemaVersion = 3;
+
+// databases written before schema versioning have no schema_version table,
+// they have version 1.
+int schemaVersion()
+{
+    auto q = QSqlQuery("SELECT version FROM schema_version;", QSqlDatabase::database());
+    if (q.next())
+    {
+        return q.value(0).toInt();
+    }
+    return 1;
+}
 
 int maxGenerationNumber()
 {
@@ -95,6 +110,12 @@ void MainWindow::followEvolution()
     auto db = QSqlDatabase::addDatabase("QSQLITE");
     db.setDatabaseName(fileName);
     m_dbOpened = db.open();
+    if (m_dbOpened && schemaVersion() > SupportedSchemaVersion)
+    {
+        qWarning() << "unsupported database schema version:" << schemaVersion();
+        closeDatabase();
+        m_dbOpened = false;
+    }
     if (m_dbOpened)
     {
         qInfo() << "opened database";
@@ -147,7 +168,7 @@ void MainWindow::showGenerationData(int value)
     if (m_dbOpened)
     {
         auto q = QSqlQuery(QSqlDatabase::database());
-        q.prepare("SELECT best_fitness, mean_fitness, fitness_stddev, elapsed FROM generations WHERE gen_number = ?");
+        q.prepare("SELECT best_fitness, mean_fitness, fitness_stddev, elapsed FROM generations WHERE gen_number = ? ORDER BY id DESC LIMIT 1");
         q.bindValue(0, generation);
         q.exec();
         if (q.next())
@@ -174,9 +195,18 @@ void MainWindow::onSockConnected()
 void MainWindow::onSockReadyRead()
 {
     qInfo() << "socket ready read";
-    // the socket is use to signal us new data exists in the database, so
-    // the data read can be discarded, we now have the information we want.
-    m_sock.readAll();
-    ui->generationSlider->setMaximum(maxGenerationNumber() / GenerationFrequency);
+    // each line is a JSON message signaling a new generation, carrying its
+    // number, so there's no need to query the database.
+    while (m_sock.canReadLine())
+    {
+        auto msg = QJsonDocument::fromJson(m_sock.readLine()).object();
+        auto generation = msg.value("generation").toInt(-1);
+        if (generation < 0)
+        {
+            qWarning() << "invalid generation message";
+            continue;
+        }
+        ui->generationSlider->setMaximum(std::max(ui->generationSlider->maximum(), generation / GenerationFrequency));
+    }
 }
 
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aurelien-rainone/artificial/evolver"
+	cfg "github.com/jinzhu/configor"
+)
+
+// batchJob is a reference image of a batch, with its configuration overrides.
+type batchJob struct {
+	image     string                 // reference image path
+	out       string                 // output directory, relative to the batch one
+	overrides map[string]interface{} // configuration keys overriding the base configuration
+	opts      evolver.Options
+}
+
+// batchManifest is the YAML (or JSON) batch manifest.
+type batchManifest struct {
+	Images []struct {
+		// Image is the path of the reference image, relative to the
+		// manifest directory
+		Image string
+		// Out is the output directory of the image, defaults to the image
+		// file name without extension
+		Out string
+		// Config overrides the base configuration
+		Config map[string]interface{}
+	}
+}
+
+// referenceExts are the extensions of the reference images of a batch
+// directory.
+var referenceExts = map[string]bool{
+	".png": true, ".jpg": true, ".jpeg": true, ".gif": true,
+	".webp": true, ".bmp": true, ".tif": true, ".tiff": true,
+}
+
+// batchCmd implements the batch subcommand, that evolves a set of reference
+// images, each into its own output directory, then writes a summary report.
+func batchCmd(args []string) error {
+	fs := flag.NewFlagSet("batch", flag.ExitOnError)
+	configFile := fs.String("cfg", "config.yml", "base configuration file")
+	out := fs.String("o", "_batch", "output directory, containing one directory per image")
+	jobs := fs.Int("j", 1, "number of images evolved concurrently")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s batch [flags] dir|manifest.yml|manifest.csv\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "A CSV manifest has an image column, an optional out column, and one column per overridden configuration key, like mutation.image.addpoly.")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *jobs < 1 {
+		return fmt.Errorf("invalid number of concurrent jobs %v", *jobs)
+	}
+
+	var base evolver.Options
+	if err := cfg.Load(&base, *configFile); err != nil {
+		return fmt.Errorf("read config error: %v", err)
+	}
+	batch, err := readBatch(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if len(batch) == 0 {
+		return fmt.Errorf("no reference image in %v", fs.Arg(0))
+	}
+
+	// check all configurations before starting
+	for _, job := range batch {
+		if job.opts, err = overrideOptions(base, job.overrides); err != nil {
+			return fmt.Errorf("%v: %v", job.image, err)
+		}
+		if err = validateOutput(&job.opts); err != nil {
+			return fmt.Errorf("%v: %v", job.image, err)
+		}
+		job.opts.RefImage = job.image
+		job.opts.Observe.OutDir = path.Join(*out, job.out)
+		if *jobs > 1 && job.opts.Observe.HTTP.Enabled {
+			return fmt.Errorf("%v: the http observer can't be enabled with concurrent jobs", job.image)
+		}
+		shareWorkers(&job.opts, *jobs)
+		if !hasTermination(&job.opts) {
+			log.Printf("warning: %v has no termination condition, it only stops when interrupted", job.image)
+		}
+	}
+
+	ctx := interruptContext()
+	results := make([]batchResult, len(batch))
+	runJobs(ctx, len(batch), *jobs, func(ctx context.Context, i int) {
+		results[i] = runBatchJob(ctx, batch[i])
+	})
+
+	return writeBatchSummary(path.Join(*out, "summary.csv"), batch, results)
+}
+
+// batchResult is the outcome of a batch job.
+type batchResult struct {
+	res      *evolver.Result
+	err      error
+	skipped  bool // interrupted before the job started
+	duration time.Duration
+}
+
+// runBatchJob evolves the reference image of job, and saves its best
+// candidate in its output directory.
+func runBatchJob(ctx context.Context, job *batchJob) batchResult {
+	if ctx.Err() != nil {
+		return batchResult{skipped: true}
+	}
+	log.Printf("evolving %v into %v", job.image, job.opts.Observe.OutDir)
+	start := time.Now()
+	img, err := readRefImage(job.image)
+	if err != nil {
+		return batchResult{err: err}
+	}
+	res, err := evolver.Run(ctx, img, job.opts)
+	if err != nil {
+		return batchResult{err: err}
+	}
+	if err = saveBest(job.opts.Observe.OutDir, res.Best, &job.opts); err != nil {
+		return batchResult{res: res, err: err}
+	}
+	r := batchResult{res: res, duration: time.Since(start)}
+	log.Printf("%v: fitness %v after %v generations, in %v", job.image, res.Fitness, res.Generation, r.duration)
+	return r
+}
+
+// writeBatchSummary prints the summary of a batch and writes it as CSV in fn.
+// It returns an error if a job failed.
+func writeBatchSummary(fn string, batch []*batchJob, results []batchResult) error {
+	if err := os.MkdirAll(path.Dir(fn), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(fn)
+	if err != nil {
+		return fmt.Errorf("can't create summary: %v", err)
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"image", "outdir", "status", "generations", "fitness", "similarity", "duration_s"})
+	var failed int
+	fmt.Println("Batch summary:")
+	for i, job := range batch {
+		r := results[i]
+		status := "ok"
+		switch {
+		case r.err != nil:
+			status = r.err.Error()
+			failed++
+		case r.skipped:
+			status = "skipped"
+		}
+		row := []string{job.image, job.opts.Observe.OutDir, status, "", "", "", ""}
+		if r.res != nil && r.err == nil {
+			sim := evolver.Similarity(job.opts.Fitness.Metric, r.res.Fitness)
+			row[3] = fmt.Sprint(r.res.Generation)
+			row[4] = fmt.Sprint(r.res.Fitness)
+			row[5] = fmt.Sprintf("%.2f", sim)
+			row[6] = fmt.Sprintf("%.1f", r.duration.Seconds())
+			fmt.Printf("  %v: fitness %v (%.2f%%), %v generations, %v\n",
+				job.image, r.res.Fitness, sim, r.res.Generation, r.duration.Round(time.Second))
+		} else {
+			fmt.Printf("  %v: %v\n", job.image, status)
+		}
+		w.Write(row)
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return fmt.Errorf("can't write summary: %v", err)
+	}
+	log.Println("summary written to", fn)
+	if failed > 0 {
+		return fmt.Errorf("%v of %v images failed", failed, len(batch))
+	}
+	return nil
+}
+
+// readBatch returns the jobs of a batch directory or manifest, the output
+// directory of each job defaulting to the name of its image.
+func readBatch(src string) ([]*batchJob, error) {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	var batch []*batchJob
+	switch ext := strings.ToLower(filepath.Ext(src)); {
+	case fi.IsDir():
+		batch, err = readBatchDir(src)
+	case ext == ".csv":
+		batch, err = readBatchCSV(src)
+	case ext == ".yml" || ext == ".yaml" || ext == ".json":
+		batch, err = readBatchManifest(src)
+	default:
+		return nil, fmt.Errorf("%v isn't a directory, nor a YAML, JSON or CSV manifest", src)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	outs := make(map[string]string)
+	for _, job := range batch {
+		if job.image == "" {
+			return nil, fmt.Errorf("%v: missing image", src)
+		}
+		if !fi.IsDir() && !filepath.IsAbs(job.image) {
+			// manifest paths are relative to the manifest
+			job.image = filepath.Join(filepath.Dir(src), job.image)
+		}
+		if job.out == "" {
+			job.out = strings.TrimSuffix(filepath.Base(job.image), filepath.Ext(job.image))
+		}
+		if other, ok := outs[job.out]; ok {
+			return nil, fmt.Errorf("%v and %v have the same output directory %q", other, job.image, job.out)
+		}
+		outs[job.out] = job.image
+	}
+	return batch, nil
+}
+
+// readBatchDir returns a job per reference image of dir.
+func readBatchDir(dir string) ([]*batchJob, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var batch []*batchJob
+	for _, fi := range fis {
+		if !fi.IsDir() && referenceExts[strings.ToLower(filepath.Ext(fi.Name()))] {
+			batch = append(batch, &batchJob{image: filepath.Join(dir, fi.Name())})
+		}
+	}
+	return batch, nil
+}
+
+// readBatchManifest returns the jobs of a YAML or JSON manifest.
+func readBatchManifest(fn string) ([]*batchJob, error) {
+	var m batchManifest
+	if strings.ToLower(filepath.Ext(fn)) == ".json" {
+		// decode numbers as json.Number, so that large integers like seeds
+		// aren't rounded
+		f, err := os.Open(fn)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		dec := json.NewDecoder(f)
+		dec.UseNumber()
+		if err = dec.Decode(&m); err != nil {
+			return nil, fmt.Errorf("can't read manifest: %v", err)
+		}
+	} else if err := cfg.Load(&m, fn); err != nil {
+		return nil, fmt.Errorf("can't read manifest: %v", err)
+	}
+	batch := make([]*batchJob, len(m.Images))
+	for i, img := range m.Images {
+		batch[i] = &batchJob{image: img.Image, out: img.Out, overrides: img.Config}
+	}
+	return batch, nil
+}
+
+// readBatchCSV returns the jobs of a CSV manifest. Its first row names the
+// columns: image, out (optional), then configuration keys. Empty cells don't
+// override the base configuration.
+func readBatchCSV(fn string) ([]*batchJob, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("can't read manifest: %v", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	header := rows[0]
+	var batch []*batchJob
+	for _, row := range rows[1:] {
+		job := &batchJob{overrides: make(map[string]interface{})}
+		for i, cell := range row {
+			switch key := strings.TrimSpace(header[i]); {
+			case key == "image":
+				job.image = cell
+			case key == "out":
+				job.out = cell
+			case cell != "":
+				job.overrides[key] = parseValue(cell)
+			}
+		}
+		batch = append(batch, job)
+	}
+	return batch, nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadBatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, content string) string {
+		fn := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return fn
+	}
+	write("images/a.png", "")
+	write("images/b.JPG", "")
+	write("images/notes.txt", "")
+	write("images/sub/c.png", "")
+
+	type job struct {
+		image, out string
+		overrides  map[string]interface{}
+	}
+	tests := []struct {
+		name    string
+		src     string
+		want    []job
+		wantErr bool
+	}{
+		{
+			name: "directory",
+			src:  filepath.Join(dir, "images"),
+			want: []job{
+				{image: filepath.Join(dir, "images/a.png"), out: "a"},
+				{image: filepath.Join(dir, "images/b.JPG"), out: "b"},
+			},
+		},
+		{
+			name: "yaml manifest",
+			src: write("batch.yml", `images:
+  - image: images/a.png
+    config:
+      seed: 1700000000000000001
+      mutation:
+        mode: gaussian
+  - image: /abs/b.png
+    out: other
+`),
+			want: []job{
+				{image: filepath.Join(dir, "images/a.png"), out: "a", overrides: map[string]interface{}{
+					"seed":     1700000000000000001,
+					"mutation": map[interface{}]interface{}{"mode": "gaussian"},
+				}},
+				{image: "/abs/b.png", out: "other"},
+			},
+		},
+		{
+			name: "json manifest",
+			src:  write("batch.json", `{"images": [{"image": "images/a.png", "config": {"seed": 1700000000000000001}}]}`),
+			want: []job{
+				{image: filepath.Join(dir, "images/a.png"), out: "a", overrides: map[string]interface{}{
+					"seed": json.Number("1700000000000000001"),
+				}},
+			},
+		},
+		{
+			name: "csv manifest",
+			src:  write("batch.csv", "image,out,mutation.mode,seed\nimages/a.png,,gaussian,\nimages/b.png,b2,,12\n"),
+			want: []job{
+				{image: filepath.Join(dir, "images/a.png"), out: "a", overrides: map[string]interface{}{"mutation.mode": "gaussian"}},
+				{image: filepath.Join(dir, "images/b.png"), out: "b2", overrides: map[string]interface{}{"seed": json.Number("12")}},
+			},
+		},
+		{
+			name:    "duplicate output directory",
+			src:     write("dup.csv", "image,out\na.png,x\nb.png,x\n"),
+			wantErr: true,
+		},
+		{
+			name:    "duplicate default output directory",
+			src:     write("dup.yml", "images:\n  - image: a.png\n  - image: sub/a.png\n"),
+			wantErr: true,
+		},
+		{
+			name:    "missing image",
+			src:     write("missing.csv", "image,out\n,x\n"),
+			wantErr: true,
+		},
+		{
+			name:    "unknown manifest type",
+			src:     write("batch.txt", ""),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		batch, err := readBatch(tt.src)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		var got []job
+		for _, j := range batch {
+			if len(j.overrides) == 0 {
+				j.overrides = nil
+			}
+			got = append(got, job{j.image, j.out, j.overrides})
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -1,82 +1,198 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"strings"
 
+	"github.com/aurelien-rainone/artificial/evolver"
 	cfg "github.com/jinzhu/configor"
 )
 
-var appConfig = struct {
-	// path to the reference image
-	RefImage string
+var appConfig evolver.Options
 
-	Population struct {
-		// number of individuals in the population
-		NumIndividuals int `required:"true"`
-		// number of candidates preserved through elitism
-		EliteCount int `required:"true"`
+var (
+	configFile  = flag.String("cfg", "config.yml", "configuration file")
+	refImage    = flag.String("img", "", "reference image (PNG, JPEG, GIF, WebP, BMP or TIFF)")
+	cpuprofile  = flag.String("cpuprofile", "", "write cpu profile to file")
+	resumeDir   = flag.String("resume", "", "resume evolution from the checkpoint in this directory")
+	renderDir   = flag.String("render", "", "render the best candidate of the checkpoint in this directory, then exit")
+	renderGen   = flag.Int("render-gen", -1, "with -render, render the best candidate of this generation from the evolution database instead")
+	renderScale = flag.Float64("render-scale", 0, "scale factor applied to the best candidate rendering")
+	renderSize  = flag.String("render-size", "", "size of the best candidate rendering, as WxH")
+	rngSeed     = flag.Int64("seed", 0, "seed of the pseudo random number generator (0 for random)")
+	httpAddr    = flag.String("http", "", "serve evolution progress over HTTP on this address (e.g. :8080)")
+	outDir      = flag.String("out", "", "output directory (default: a new directory in _output)")
+)
+
+func readConfig() error {
+	flag.Parse()
+
+	err := cfg.Load(&appConfig, *configFile)
+	if err != nil {
+		return fmt.Errorf("read config error: %v", err)
+	}
+	fmt.Println(appConfig)
+	if len(*refImage) > 0 {
+		appConfig.RefImage = *refImage
+	}
+	if *rngSeed != 0 {
+		appConfig.Seed = *rngSeed
+	}
+	if len(*httpAddr) > 0 {
+		appConfig.Observe.HTTP.Enabled = true
+		appConfig.Observe.HTTP.Addr = *httpAddr
+	}
+	if len(*outDir) > 0 {
+		appConfig.Observe.OutDir = *outDir
 	}
 
-	Image struct {
-		// MinPolys is the minimum number of polygon in an image
-		MinPolys int `required:"true"`
-		// MaxPolys is the minimum number of polygon in an image
-		MaxPolys int `required:"true"`
+	return validateOutput(&appConfig)
+}
+
+// validateOutput checks the output image settings of opts.
+func validateOutput(opts *evolver.Options) error {
+	switch opts.Output.Format {
+	case evolver.OutputPNG:
+	case evolver.OutputJPEG:
+		if opts.Output.Quality < 1 || opts.Output.Quality > 100 {
+			return fmt.Errorf("jpeg quality must be in [1, 100], got %v", opts.Output.Quality)
+		}
+	default:
+		return fmt.Errorf("unknown output format %q", opts.Output.Format)
 	}
+	return nil
+}
 
-	Polygon struct {
-		// MinPoints is the minimum number of points in a polygon
-		MinPoints int `required:"true"`
-		// MaxPoints is the maximum number of points in a polygon
-		MaxPoints int `required:"true"`
+// overrideOptions returns a copy of base in which the configuration keys of
+// overrides are set. Keys are named as in the configuration file, nested
+// sections being either nested maps or dotted keys, like
+// "mutation.image.addpoly". Lists replace the base ones.
+func overrideOptions(base evolver.Options, overrides map[string]interface{}) (evolver.Options, error) {
+	// base options as nested maps, numbers being kept as json.Number so that
+	// large integers aren't rounded
+	var opts evolver.Options
+	buf, err := json.Marshal(base)
+	if err != nil {
+		return opts, err
 	}
+	var nested map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	if err = dec.Decode(&nested); err != nil {
+		return opts, err
+	}
+	nested = lowerKeys(nested).(map[string]interface{})
 
-	Mutation struct {
-		// image level mutations
-		Image struct {
-			// Rate [0, 1] of add polygon mutation
-			AddPoly float64 `required:"true"`
-			// Rate [0, 1] of remove polygon mutation
-			RemovePoly float64 `required:"true"`
-			// Rate [0, 1] of swap polygon mutation
-			SwapPolys float64 `required:"true"`
+	for k, v := range overrides {
+		if err := setKey(nested, k, v); err != nil {
+			return opts, err
 		}
+	}
+	if buf, err = json.Marshal(nested); err != nil {
+		return opts, fmt.Errorf("invalid config overrides: %v", err)
+	}
+	// JSON field names match configuration keys case-insensitively
+	dec = json.NewDecoder(bytes.NewReader(buf))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&opts); err != nil {
+		return opts, fmt.Errorf("invalid config overrides: %v", err)
+	}
+	opts.Resume, opts.Observers = base.Resume, base.Observers
+	return opts, nil
+}
 
-		// polygon level mutations
-		Polygon struct {
-			// Rate [0, 1] of add point mutation
-			AddPoint float64 `required:"true"`
-			// Rate [0, 1] of remove point mutation
-			RemovePoint float64 `required:"true"`
-			// Rate [0, 1] of change polygon color mutation
-			ChangeColor float64 `required:"true"`
+// lowerKeys returns v, a value decoded from JSON, with all map keys in lower
+// case.
+func lowerKeys(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[strings.ToLower(k)] = lowerKeys(e)
 		}
-
-		// point level mutations
-		Point struct {
-			// Rate [0, 1] of move point mutation
-			Move float64 `required:"true"`
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = lowerKeys(e)
 		}
 	}
-}{}
-
-var (
-	configFile = flag.String("cfg", "config.yml", "configuration file")
-	refImage   = flag.String("img", "", "reference image (PNG)")
-	cpuprofile = flag.String("cpuprofile", "", "write cpu profile to file")
-)
+	return v
+}
 
-func readConfig() error {
-	flag.Parse()
+// setKey sets the configuration key k of the nested map m to v, merging
+// nested sections.
+func setKey(m map[string]interface{}, k string, v interface{}) error {
+	keys := strings.Split(strings.ToLower(k), ".")
+	for _, key := range keys[:len(keys)-1] {
+		if m[key] == nil {
+			m[key] = make(map[string]interface{})
+		}
+		sub, ok := m[key].(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("config key %q isn't a section", key)
+		}
+		m = sub
+	}
+	key := keys[len(keys)-1]
 
-	err := cfg.Load(&appConfig, *configFile)
-	if err != nil {
-		return fmt.Errorf("read config error: %v", err)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for sk, sv := range v {
+			if err := setKey(m, key+"."+sk, sv); err != nil {
+				return err
+			}
+		}
+		return nil
+	case map[interface{}]interface{}:
+		// nested maps decoded from YAML
+		for sk, sv := range v {
+			if err := setKey(m, fmt.Sprintf("%v.%v", key, sk), sv); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		// lists of nested maps decoded from YAML
+		list := make([]interface{}, len(v))
+		for i, e := range v {
+			if em, ok := e.(map[interface{}]interface{}); ok {
+				sub := make(map[string]interface{})
+				for sk, sv := range em {
+					if err := setKey(sub, fmt.Sprint(sk), sv); err != nil {
+						return err
+					}
+				}
+				e = sub
+			}
+			list[i] = e
+		}
+		m[key] = list
+		return nil
 	}
-	fmt.Println(appConfig)
-	if len(*refImage) > 0 {
-		appConfig.RefImage = *refImage
+	if _, ok := m[key].(map[string]interface{}); ok {
+		return fmt.Errorf("config key %q is a section", k)
 	}
+	m[key] = v
 	return nil
 }
+
+// parseValue parses the value of a configuration key given as text, as JSON
+// (numbers, booleans, lists...) if possible, as a string otherwise. Numbers
+// are kept as json.Number, so that large integers like seeds aren't rounded.
+func parseValue(s string) interface{} {
+	var v interface{}
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return s
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		// trailing data
+		return s
+	}
+	return v
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/aurelien-rainone/artificial/evolver"
+)
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		s    string
+		want interface{}
+	}{
+		{"0.25", json.Number("0.25")},
+		{"1700000000000000001", json.Number("1700000000000000001")},
+		{"true", true},
+		{"gaussian", "gaussian"},
+		{`"42"`, "42"},
+		{"[1, 2]", []interface{}{json.Number("1"), json.Number("2")}},
+		{"1 2", "1 2"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := parseValue(tt.s); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseValue(%q) = %#v, want %#v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestSetKey(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides map[string]interface{}
+		want      map[string]interface{}
+		wantErr   bool
+	}{
+		{
+			name:      "dotted",
+			overrides: map[string]interface{}{"Mutation.Image.AddPoly": 0.5},
+			want:      map[string]interface{}{"mutation": map[string]interface{}{"image": map[string]interface{}{"addpoly": 0.5}}},
+		},
+		{
+			name: "nested merged with dotted",
+			overrides: map[string]interface{}{
+				"mutation":              map[string]interface{}{"image": map[string]interface{}{"addpoly": 0.5}},
+				"mutation.image.rmpoly": 0.1,
+			},
+			want: map[string]interface{}{"mutation": map[string]interface{}{"image": map[string]interface{}{"addpoly": 0.5, "rmpoly": 0.1}}},
+		},
+		{
+			name:      "yaml nested maps",
+			overrides: map[string]interface{}{"mutation": map[interface{}]interface{}{"mode": "gaussian"}},
+			want:      map[string]interface{}{"mutation": map[string]interface{}{"mode": "gaussian"}},
+		},
+		{
+			name: "yaml list of maps",
+			overrides: map[string]interface{}{"pyramid.stages": []interface{}{
+				map[interface{}]interface{}{"Scale": 0.5, "generations": 10},
+			}},
+			want: map[string]interface{}{"pyramid": map[string]interface{}{"stages": []interface{}{
+				map[string]interface{}{"scale": 0.5, "generations": 10},
+			}}},
+		},
+		{
+			name:      "value is a section",
+			overrides: map[string]interface{}{"seed.value": 1},
+			want:      map[string]interface{}{"seed": map[string]interface{}{"value": 1}},
+		},
+	}
+	for _, tt := range tests {
+		m := make(map[string]interface{})
+		for k, v := range tt.overrides {
+			if err := setKey(m, k, v); err != nil {
+				t.Fatalf("%v: %v", tt.name, err)
+			}
+		}
+		if !reflect.DeepEqual(m, tt.want) {
+			t.Errorf("%v: got %v, want %v", tt.name, m, tt.want)
+		}
+	}
+}
+
+func TestSetKeyConflicts(t *testing.T) {
+	m := make(map[string]interface{})
+	if err := setKey(m, "mutation.image.addpoly", 0.5); err != nil {
+		t.Fatal(err)
+	}
+	if err := setKey(m, "mutation.image", 1); err == nil {
+		t.Errorf("want error setting a section to a value")
+	}
+	if err := setKey(m, "mutation.image.addpoly.rate", 1); err == nil {
+		t.Errorf("want error setting a key of a value")
+	}
+}
+
+func TestOverrideOptions(t *testing.T) {
+	var base evolver.Options
+	base.Seed = 3
+	base.Fitness.Metric = "mse"
+	base.Pyramid.Stages = []evolver.PyramidStage{{Scale: 0.5, Generations: 10}}
+
+	tests := []struct {
+		name      string
+		overrides map[string]interface{}
+		check     func(*evolver.Options) bool
+		wantErr   bool
+	}{
+		{
+			name:      "large seed",
+			overrides: map[string]interface{}{"seed": parseValue("1700000000000000001")},
+			check:     func(o *evolver.Options) bool { return o.Seed == 1700000000000000001 },
+		},
+		{
+			name:      "dotted key",
+			overrides: map[string]interface{}{"mutation.image.addpoly": parseValue("0.25")},
+			check:     func(o *evolver.Options) bool { return o.Mutation.Image.AddPoly == 0.25 && o.Seed == 3 },
+		},
+		{
+			name:      "nested yaml key",
+			overrides: map[string]interface{}{"fitness": map[interface{}]interface{}{"metric": "ssim"}},
+			check:     func(o *evolver.Options) bool { return o.Fitness.Metric == "ssim" },
+		},
+		{
+			name: "yaml list",
+			overrides: map[string]interface{}{"pyramid": map[interface{}]interface{}{"stages": []interface{}{
+				map[interface{}]interface{}{"scale": 0.25, "stagnation": 5},
+				map[interface{}]interface{}{"scale": 0.5, "generations": 20},
+			}}},
+			check: func(o *evolver.Options) bool {
+				return reflect.DeepEqual(o.Pyramid.Stages, []evolver.PyramidStage{{Scale: 0.25, Stagnation: 5}, {Scale: 0.5, Generations: 20}})
+			},
+		},
+		{name: "unknown key", overrides: map[string]interface{}{"mutation.image.nope": 1}, wantErr: true},
+		{name: "wrong type", overrides: map[string]interface{}{"seed": "abc"}, wantErr: true},
+		{name: "section set to a value", overrides: map[string]interface{}{"mutation": 1}, wantErr: true},
+		{name: "value set to a section", overrides: map[string]interface{}{"seed.value": 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		opts, err := overrideOptions(base, tt.overrides)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%v: error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !tt.check(&opts) {
+			t.Errorf("%v: got options %+v", tt.name, opts)
+		}
+	}
+
+	// base is left untouched
+	if base.Seed != 3 || base.Fitness.Metric != "mse" || base.Pyramid.Stages[0].Scale != 0.5 {
+		t.Errorf("overrideOptions modified the base options: %+v", base)
+	}
+}
@@ -0,0 +1,164 @@
+package evolver
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+
+	"github.com/aurelien-rainone/evolve/framework"
+)
+
+// checkpointName is the name of the checkpoint file in the output directory.
+const checkpointName = "checkpoint.json"
+
+// Checkpoint is a snapshot of an evolution run, from which the run can be
+// resumed.
+type Checkpoint struct {
+	Generation int         `json:"generation"` // generation of the snapshot
+	Seed       int64       `json:"seed"`       // seed of the pseudo random number generator
+	Best       *ImageDNA   `json:"best"`       // best candidate of the generation
+	Population []*ImageDNA `json:"population"` // whole population
+}
+
+// WriteCheckpoint atomically writes ckpt into the checkpoint file of dir.
+func WriteCheckpoint(dir string, ckpt *Checkpoint) error {
+	buf, err := json.Marshal(ckpt)
+	if err != nil {
+		return fmt.Errorf("can't encode checkpoint: %v", err)
+	}
+
+	// write to a temporary file first, so that we never leave a partially
+	// written checkpoint if we get interrupted.
+	tmp := path.Join(dir, checkpointName+".tmp")
+	if err = ioutil.WriteFile(tmp, buf, 0644); err != nil {
+		return fmt.Errorf("can't write checkpoint: %v", err)
+	}
+	return os.Rename(tmp, path.Join(dir, checkpointName))
+}
+
+// ReadCheckpoint reads the checkpoint file of dir.
+func ReadCheckpoint(dir string) (*Checkpoint, error) {
+	buf, err := ioutil.ReadFile(path.Join(dir, checkpointName))
+	if err != nil {
+		return nil, fmt.Errorf("can't read checkpoint: %v", err)
+	}
+	ckpt := &Checkpoint{}
+	if err = json.Unmarshal(buf, ckpt); err != nil {
+		return nil, fmt.Errorf("can't decode checkpoint: %v", err)
+	}
+	if len(ckpt.Population) == 0 {
+		return nil, fmt.Errorf("checkpoint has an empty population")
+	}
+	if ckpt.Best == nil {
+		// checkpoint written before best candidates were recorded
+		ckpt.Best = ckpt.Population[0]
+	}
+	return ckpt, nil
+}
+
+// candidates returns the checkpointed population, truncated to size.
+func (ckpt *Checkpoint) candidates(size int) []framework.Candidate {
+	cands := make([]framework.Candidate, 0, size)
+	for i := 0; i < len(ckpt.Population) && i < size; i++ {
+		cands = append(cands, ckpt.Population[i])
+	}
+	return cands
+}
+
+// serialized form of ImageDNA
+type imageDNAJSON struct {
+	W     int        `json:"w"`
+	H     int        `json:"h"`
+	Polys []polyJSON `json:"polys"`
+}
+
+// serialized form of poly
+type polyJSON struct {
+	Shape string   `json:"shape,omitempty"` // empty for polygons
+	Col   [4]uint8 `json:"col"`             // non-alpha-premultiplied R, G, B, A
+	Pts   [][2]int `json:"pts"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (img *ImageDNA) MarshalJSON() ([]byte, error) {
+	dna := imageDNAJSON{W: img.W, H: img.H, Polys: make([]polyJSON, len(img.Polys))}
+	for i, p := range img.Polys {
+		if p.Shape != ShapePolygon {
+			dna.Polys[i].Shape = p.Shape.String()
+		}
+		col := color.NRGBAModel.Convert(p.Col).(color.NRGBA)
+		dna.Polys[i].Col = [4]uint8{col.R, col.G, col.B, col.A}
+		dna.Polys[i].Pts = make([][2]int, len(p.Pts))
+		for j, pt := range p.Pts {
+			dna.Polys[i].Pts[j] = [2]int{pt.X, pt.Y}
+		}
+	}
+	return json.Marshal(dna)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (img *ImageDNA) UnmarshalJSON(buf []byte) error {
+	var dna imageDNAJSON
+	if err := json.Unmarshal(buf, &dna); err != nil {
+		return err
+	}
+	if dna.W <= 0 || dna.H <= 0 {
+		return fmt.Errorf("invalid dimensions %v x %v", dna.W, dna.H)
+	}
+	img.W, img.H = dna.W, dna.H
+	img.Polys = make([]Poly, len(dna.Polys))
+	for i, p := range dna.Polys {
+		if len(p.Pts) == 0 {
+			return fmt.Errorf("polygon %d has no points", i)
+		}
+		if p.Shape != "" {
+			shape, err := ParseShape(p.Shape)
+			if err != nil {
+				return fmt.Errorf("polygon %d: %v", i, err)
+			}
+			if n := shape.numPoints(); n != 0 && len(p.Pts) != n {
+				return fmt.Errorf("polygon %d: %v has %d points, want %d", i, shape, len(p.Pts), n)
+			}
+			img.Polys[i].Shape = shape
+		}
+		img.Polys[i].Col = color.NRGBA{R: p.Col[0], G: p.Col[1], B: p.Col[2], A: p.Col[3]}
+		img.Polys[i].Pts = make([]image.Point, len(p.Pts))
+		for j, pt := range p.Pts {
+			img.Polys[i].Pts[j] = image.Pt(pt[0], pt[1])
+		}
+	}
+	return nil
+}
+
+// populationRecorder is a fitness evaluator that keeps track of the last
+// evaluated population, while delegating fitness evaluation to another
+// evaluator.
+//
+// The evolution engine only gives the best candidate to observers, recording
+// the population it passes to the evaluator gives them access to all the
+// candidates of the current generation.
+type populationRecorder struct {
+	framework.FitnessEvaluator
+
+	mu  sync.Mutex
+	pop []framework.Candidate
+}
+
+func (r *populationRecorder) Fitness(c framework.Candidate, pop []framework.Candidate) float64 {
+	r.mu.Lock()
+	r.pop = pop
+	r.mu.Unlock()
+	return r.FitnessEvaluator.Fitness(c, pop)
+}
+
+// population returns the last evaluated population.
+func (r *populationRecorder) population() []framework.Candidate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pop
+}
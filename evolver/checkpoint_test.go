@@ -0,0 +1,98 @@
+package evolver
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestCheckpointRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	img := &ImageDNA{
+		W: 20, H: 10,
+		Polys: []Poly{
+			Poly{
+				Col: color.NRGBA{R: 1, G: 2, B: 3, A: 4},
+				Pts: []image.Point{{0, 0}, {5, 1}, {2, 8}},
+			},
+		},
+	}
+	want := &Checkpoint{Generation: 42, Seed: 99, Best: img, Population: []*ImageDNA{img, img.clone()}}
+	if err = WriteCheckpoint(dir, want); err != nil {
+		t.Fatalf("writeCheckpoint error: %v", err)
+	}
+	got, err := ReadCheckpoint(dir)
+	if err != nil {
+		t.Fatalf("readCheckpoint error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got checkpoint %+v, want %+v", got, want)
+	}
+}
+
+func TestResumeGenerations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resume")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	opts := testOptions()
+	opts.Termination.Generations = 10
+	opts.Output.Format = OutputPNG
+	opts.Observe.OutDir = dir
+	opts.Observe.Snapshot.Enabled = true
+	opts.Observe.Snapshot.Frequency = 5
+	opts.Observe.Snapshot.Pattern = "%d"
+	opts.Observe.Database.Enabled = true
+	opts.Observe.Database.Frequency = 5
+	opts.Observe.Checkpoint.Enabled = true
+	opts.Observe.Checkpoint.Frequency = 100
+	if _, err = Run(context.Background(), testReference(), opts); err != nil {
+		t.Fatal(err)
+	}
+	ckpt, err := ReadCheckpoint(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ckpt.Generation != 9 {
+		t.Fatalf("got checkpoint of generation %v, want 9", ckpt.Generation)
+	}
+
+	// the resumed run continues the generation numbers of the checkpoint
+	opts.Resume = ckpt
+	opts.Termination.Generations = 20
+	res, err := Run(context.Background(), testReference(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Generation != 19 {
+		t.Errorf("resumed run ended at generation %v, want 19", res.Generation)
+	}
+	for _, fn := range []string{"10.png", "15.png"} {
+		if _, err = os.Stat(path.Join(dir, fn)); err != nil {
+			t.Errorf("missing snapshot: %v", err)
+		}
+	}
+	var gens []int
+	err = readBestGenomes(dir, func(generation int, fitness float64, img *ImageDNA) error {
+		gens = append(gens, generation)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(gens, []int{10, 15}) {
+		t.Errorf("resumed run recorded generations %v, want [10 15]", gens)
+	}
+}
@@ -1,100 +1,132 @@
-package main
+package evolver
 
 import (
 	"image"
 	"image/color"
 	"math"
 	"math/rand"
-	"time"
+	"sync"
 
 	"github.com/fogleman/gg"
-	gorng "github.com/leesper/go_rng"
 )
 
-// poly represents a polygon of the image
-type poly struct {
-	col color.Color
-	pts []image.Point
+// Poly represents a shape of the image, a polygon unless Shape says otherwise
+type Poly struct {
+	Shape Shape
+	Col   color.Color
+	Pts   []image.Point
 }
 
-func (p *poly) insert(idx int, pt image.Point) {
+func (p *Poly) insert(idx int, pt image.Point) {
 	// append a zero-value at the back
-	p.pts = append(p.pts, image.Point{})
+	p.Pts = append(p.Pts, image.Point{})
 	// right-shift all elements after the insertion point
-	copy(p.pts[idx+1:], p.pts[idx:])
+	copy(p.Pts[idx+1:], p.Pts[idx:])
 	// set the inserted element at given index
-	p.pts[idx] = pt
+	p.Pts[idx] = pt
 }
 
-// imageDNA is a gene coding for an image made of polygons
-type imageDNA struct {
-	w, h  int
-	polys []poly
+// ImageDNA is a gene coding for an image made of polygons
+type ImageDNA struct {
+	W, H  int
+	Polys []Poly
+
+	// incremental evaluation, see incremental.go
+	mu     sync.Mutex
+	cache  *renderCache    // rendering and error, once evaluated
+	parent *renderCache    // rendering and error of the parent
+	dirty  image.Rectangle // region that differs from the parent rendering
 }
 
-// clone returns a new imageDNA that is an exact copy of the receiver
-func (img *imageDNA) clone() *imageDNA {
+// clone returns a new ImageDNA that is an exact copy of the receiver
+func (img *ImageDNA) clone() *ImageDNA {
 	// copy polygon slice
-	polys := make([]poly, len(img.polys))
-	for i, p := range img.polys {
-		poly := poly{col: p.col}
+	polys := make([]Poly, len(img.Polys))
+	for i, p := range img.Polys {
+		poly := Poly{Shape: p.Shape, Col: p.Col}
 		// copy points slice
-		poly.pts = make([]image.Point, len(p.pts))
-		copy(poly.pts, p.pts)
+		poly.Pts = make([]image.Point, len(p.Pts))
+		copy(poly.Pts, p.Pts)
 		polys[i] = poly
 	}
-	return &imageDNA{polys: polys, w: img.w, h: img.h}
+	return &ImageDNA{Polys: polys, W: img.W, H: img.H}
+}
+
+// rescale returns a copy of img coding the same image on a w x h reference
+// image, its polygon coordinates being scaled accordingly.
+func (img *ImageDNA) rescale(w, h int) *ImageDNA {
+	sx := float64(w) / float64(img.W)
+	sy := float64(h) / float64(img.H)
+	scaled := img.clone()
+	scaled.W, scaled.H = w, h
+	for i := range scaled.Polys {
+		for j, pt := range scaled.Polys[i].Pts {
+			scaled.Polys[i].Pts[j] = image.Pt(int(math.Round(float64(pt.X)*sx)), int(math.Round(float64(pt.Y)*sy)))
+		}
+	}
+	return scaled
+}
+
+// Render renders the image coded by img at the dimensions of the reference
+// image.
+func (img *ImageDNA) Render() *image.RGBA {
+	return img.RenderSize(img.W, img.H)
 }
 
-func (img *imageDNA) render() *image.RGBA {
+// RenderSize renders the image coded by img on a w x h canvas, polygon
+// coordinates being scaled (and thus possibly fractional) to fit the canvas.
+func (img *ImageDNA) RenderSize(w, h int) *image.RGBA {
 	// Initialize the graphic context on an RGBA image
-	dst := image.NewRGBA(image.Rect(0, 0, img.w, img.h))
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
 	dc := gg.NewContextForRGBA(dst)
 	dc.SetLineWidth(0)
 
-	for i := 0; i < len(img.polys); i++ {
-		dc.ClearPath()
-		poly := img.polys[i]
-		dc.SetColor(poly.col)
-		dc.MoveTo(float64(poly.pts[0].X), float64(poly.pts[0].Y))
-
-		// draw polygon as a closed path
-		for j := 1; j < len(poly.pts); j++ {
-			pt := poly.pts[j]
-			dc.LineTo(float64(pt.X), float64(pt.Y))
-		}
-		// set fill and close path
-		dc.Fill()
+	sx := float64(w) / float64(img.W)
+	sy := float64(h) / float64(img.H)
+	for i := 0; i < len(img.Polys); i++ {
+		drawPoly(dc, &img.Polys[i], sx, sy)
 	}
 	return dst
 }
 
+// drawPoly draws p on dc, scaling its coordinates by sx and sy.
+func drawPoly(dc *gg.Context, p *Poly, sx, sy float64) {
+	dc.ClearPath()
+	dc.SetColor(p.Col)
+	dc.Push()
+	dc.Scale(sx, sy)
+	p.path(dc)
+	dc.Pop()
+	// set fill and close path
+	dc.Fill()
+}
+
 // randomSimplePoly creates and returns a random simple polygon.
-func randomSimplePoly(img *imageDNA, minPts, maxPts int, rng *rand.Rand) poly {
-	poly := poly{}
+func randomSimplePoly(img *ImageDNA, minPts, maxPts int, rng *rand.Rand) Poly {
+	poly := Poly{}
 
 	// create random number of points
 	numPts := minPts + rng.Intn(maxPts-minPts)
 
 	// compute random polygon average radius (5-30% of the image size)
-	minRadius := (img.w * 5) / 100
-	maxRadius := (img.w * 30) / 100
+	minRadius := (img.W * 5) / 100
+	maxRadius := (img.W * 30) / 100
 	margin := minRadius + rng.Intn(maxRadius-minRadius)
 
 	// random point to be the polygon center
 	center := randomPoint(img, margin, rng)
 
 	// use polygon generator
-	poly.pts = generatePolygon(center, float64(margin), 0.7, 0.5, numPts, rng)
+	poly.Pts = generatePolygon(center, float64(margin), 0.7, 0.5, numPts, rng)
 
 	// set random color
-	poly.col = randomColor(rng)
+	poly.Col = randomColor(rng)
 	return poly
 }
 
 // randomPoly creates and returns a random polygon.
-func randomPoly(img *imageDNA, minPts, maxPts int, rng *rand.Rand) poly {
-	poly := poly{}
+func randomPoly(img *ImageDNA, minPts, maxPts int, rng *rand.Rand) Poly {
+	poly := Poly{}
 	// create random number of points
 	var numPts int
 	if maxPts == minPts {
@@ -102,23 +134,25 @@ func randomPoly(img *imageDNA, minPts, maxPts int, rng *rand.Rand) poly {
 	} else {
 		numPts = minPts + rng.Intn(maxPts-minPts)
 	}
-	poly.pts = make([]image.Point, numPts)
+	poly.Pts = make([]image.Point, numPts)
 	for j := 0; j < numPts; j++ {
 		// each point is random
-		poly.pts[j] = randomPoint(img, 0, rng)
+		poly.Pts[j] = randomPoint(img, 0, rng)
 	}
 	// set random color
-	poly.col = randomColor(rng)
+	poly.Col = randomColor(rng)
 	return poly
 }
 
 // randomPoint creates and returns a random point in the image
 //
-// margin is the min distance in pixel from the image border
-func randomPoint(img *imageDNA, margin int, rng *rand.Rand) image.Point {
+// margin is the min distance in pixel from the image border, reduced on images
+// too small for it.
+func randomPoint(img *ImageDNA, margin int, rng *rand.Rand) image.Point {
+	mx, my := min(margin, (img.W-1)/2), min(margin, (img.H-1)/2)
 	return image.Point{
-		X: margin + rng.Intn(img.w-2*margin),
-		Y: margin + rng.Intn(img.h-2*margin),
+		X: mx + rng.Intn(img.W-2*mx),
+		Y: my + rng.Intn(img.H-2*my),
 	}
 }
 
@@ -132,13 +166,6 @@ func randomColor(rng *rand.Rand) color.Color {
 	}
 }
 
-var gauss *gorng.GaussianGenerator
-
-func init() {
-	// instantiate the gaussian generator
-	gauss = gorng.NewGaussianGenerator(time.Now().UnixNano())
-}
-
 // Start with the centre of the polygon at ctrX, ctrY,
 // then creates the polygon by sampling points on a circle around the centre.
 // Randon noise is added by varying the angular spacing between sequential points,
@@ -180,7 +207,8 @@ func generatePolygon(ctr image.Point, avgRadius, irregularity, spikeyness float6
 	angle := rng.Float64() * 2 * math.Pi
 	var x, y float64
 	for i := 0; i < numPts; i++ {
-		ri := f64Clip(gauss.Gaussian(avgRadius, spikeyness), 0, 2*avgRadius)
+		// gaussian distributed radius, drawn from rng so that runs are reproducible
+		ri := f64Clip(avgRadius+spikeyness*rng.NormFloat64(), 0, 2*avgRadius)
 		x = float64(ctr.X) + ri*math.Cos(angle)
 		y = float64(ctr.Y) + ri*math.Sin(angle)
 		points[i] = image.Pt(int(x), int(y))
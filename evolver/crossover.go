@@ -0,0 +1,232 @@
+package evolver
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/aurelien-rainone/evolve/framework"
+	"github.com/aurelien-rainone/evolve/number"
+	"github.com/aurelien-rainone/evolve/operators"
+)
+
+// names of the available crossover operators, as they appear in the
+// configuration
+const (
+	CrossoverUnequal = "unequal"
+	CrossoverUniform = "uniform"
+	CrossoverSpatial = "spatial"
+)
+
+// NewImageDNACrossover creates the crossover operator configured by params.
+func NewImageDNACrossover(params CrossoverParams) (*operators.AbstractCrossover, error) {
+	if err := params.Limits.Validate(); err != nil {
+		return nil, err
+	}
+
+	var mater operators.Mater
+	switch params.Operator {
+	case CrossoverUnequal, "":
+		mater = imageDNAMater{}
+	case CrossoverUniform:
+		mater = uniformMater{}
+	case CrossoverSpatial:
+		mater = spatialMater{limits: params.Limits}
+	default:
+		return nil, fmt.Errorf("unknown crossover operator %q", params.Operator)
+	}
+
+	prob, err := number.NewProbability(params.Probability)
+	if err != nil {
+		return nil, fmt.Errorf("crossover probability error: %v", err)
+	}
+	if params.Points < 1 {
+		return nil, fmt.Errorf("number of crossover points must be at least 1, got %v", params.Points)
+	}
+	return operators.NewAbstractCrossover(mater,
+		operators.ConstantProbability(prob),
+		operators.ConstantCrossoverPoints(int64(params.Points)))
+}
+
+type imageDNAMater struct{}
+
+// imageDNAMater implements an unequal crossover as chromosomes (ImageDNA
+// instances) may code for images with different number of polygons.
+// In order to reduce the problem to an equal-length crossover, we only consider
+// a sub-sequence of the longest chromosome, that has the same length than the
+// shorter one. The start index of the considered subsequence is randomly chosen
+// between 0 and the length difference between both chromosomes.
+func (m imageDNAMater) Mate(parent1, parent2 framework.Candidate,
+	numberOfCrossoverPoints int64,
+	rng *rand.Rand) []framework.Candidate {
+
+	p1, p2 := parent1.(*ImageDNA), parent2.(*ImageDNA)
+
+	offspring1 := p1.derive()
+	offspring2 := p2.derive()
+
+	var p1min, p1max, p2min, p2max, crossIdx, shorterLen int
+
+	// Apply as many crossovers as required.
+	for i := int64(0); i < numberOfCrossoverPoints; i++ {
+		p1max = len(offspring1.Polys)
+		p2max = len(offspring2.Polys)
+		shorterLen = min(p1max, p2max)
+		if p1max == p2max {
+			p1min = 0
+			p2min = 0
+		} else if p1max < p2max {
+			// offspring2 is longer than offspring1
+			p1min = 0
+			p2min = rng.Intn(p2max - p1max)
+		} else {
+			// offspring1 is longer than offspring2
+			p2min = 0
+			p1min = rng.Intn(p1max - p2max)
+		}
+
+		if shorterLen < 2 {
+			// a single polygon has no crossover point
+			break
+		}
+		crossIdx = 1 + rng.Intn(shorterLen-1)
+		for j := 0; j < crossIdx; j++ {
+			// mark regions of swapped polygons as dirty
+			offspring1.touch(&offspring1.Polys[p1min+j])
+			offspring1.touch(&offspring2.Polys[p2min+j])
+			offspring2.touch(&offspring1.Polys[p1min+j])
+			offspring2.touch(&offspring2.Polys[p2min+j])
+			// swap elements of both offsprings
+			offspring1.Polys[p1min+j], offspring2.Polys[p2min+j] = offspring2.Polys[p2min+j], offspring1.Polys[p1min+j]
+		}
+	}
+	return []framework.Candidate{offspring1, offspring2}
+}
+
+type uniformMater struct{}
+
+// uniformMater implements a uniform crossover, in which each polygon is
+// swapped between both offsprings with a probability of 0.5.
+// As imageDNAMater, only a random sub-sequence of the longest chromosome, with
+// the same length than the shorter one, is considered. The number of crossover
+// points is ignored.
+func (m uniformMater) Mate(parent1, parent2 framework.Candidate,
+	numberOfCrossoverPoints int64,
+	rng *rand.Rand) []framework.Candidate {
+
+	p1, p2 := parent1.(*ImageDNA), parent2.(*ImageDNA)
+
+	offspring1 := p1.derive()
+	offspring2 := p2.derive()
+
+	var p1min, p2min int
+	if len(p1.Polys) < len(p2.Polys) {
+		p2min = rng.Intn(len(p2.Polys) - len(p1.Polys) + 1)
+	} else if len(p1.Polys) > len(p2.Polys) {
+		p1min = rng.Intn(len(p1.Polys) - len(p2.Polys) + 1)
+	}
+
+	for j := 0; j < min(len(p1.Polys), len(p2.Polys)); j++ {
+		if rng.Intn(2) == 0 {
+			continue
+		}
+		poly1, poly2 := &offspring1.Polys[p1min+j], &offspring2.Polys[p2min+j]
+		offspring1.touch(poly1)
+		offspring1.touch(poly2)
+		offspring2.touch(poly1)
+		offspring2.touch(poly2)
+		*poly1, *poly2 = *poly2, *poly1
+	}
+	return []framework.Candidate{offspring1, offspring2}
+}
+
+type spatialMater struct {
+	limits Limits // genome size limits
+}
+
+// spatialMater implements a spatial crossover, in which the image is split into
+// regions by a number of random horizontal or vertical cuts (one per crossover
+// point). Each offspring takes the polygons of one parent in half of the
+// regions, and the polygons of the other parent in the other half, depending
+// on the region in which lies each polygon center. The relative drawing order
+// of polygons is preserved.
+//
+// If one offspring would end up with a number of polygons out of the allowed
+// range, it's replaced by a copy of its parent.
+func (m spatialMater) Mate(parent1, parent2 framework.Candidate,
+	numberOfCrossoverPoints int64,
+	rng *rand.Rand) []framework.Candidate {
+
+	p1, p2 := parent1.(*ImageDNA), parent2.(*ImageDNA)
+
+	// draw random cuts
+	cuts := make([]spatialCut, numberOfCrossoverPoints)
+	for i := range cuts {
+		cuts[i].vertical = rng.Intn(2) == 0
+		if cuts[i].vertical {
+			cuts[i].pos = rng.Intn(p1.W)
+		} else {
+			cuts[i].pos = rng.Intn(p1.H)
+		}
+	}
+
+	// side returns true if the polygon center lies in one of the regions
+	// given to the first parent in the first offspring.
+	side := func(p *Poly) bool {
+		ctr := p.bounds().Min.Add(p.bounds().Max).Div(2)
+		s := false
+		for _, cut := range cuts {
+			if cut.vertical {
+				s = s != (ctr.X < cut.pos)
+			} else {
+				s = s != (ctr.Y < cut.pos)
+			}
+		}
+		return s
+	}
+
+	offspring1 := &ImageDNA{W: p1.W, H: p1.H}
+	offspring2 := &ImageDNA{W: p1.W, H: p1.H}
+
+	// merge polygons from both parents, respecting their relative position in
+	// the drawing order
+	i, j := 0, 0
+	for i < len(p1.Polys) || j < len(p2.Polys) {
+		if j == len(p2.Polys) || (i < len(p1.Polys) && i*len(p2.Polys) <= j*len(p1.Polys)) {
+			if side(&p1.Polys[i]) {
+				offspring1.Polys = append(offspring1.Polys, p1.Polys[i])
+			} else {
+				offspring2.Polys = append(offspring2.Polys, p1.Polys[i])
+			}
+			i++
+		} else {
+			if side(&p2.Polys[j]) {
+				offspring2.Polys = append(offspring2.Polys, p2.Polys[j])
+			} else {
+				offspring1.Polys = append(offspring1.Polys, p2.Polys[j])
+			}
+			j++
+		}
+	}
+
+	valid := func(img *ImageDNA) bool {
+		return len(img.Polys) >= m.limits.MinPolys && len(img.Polys) <= m.limits.MaxPolys
+	}
+	if !valid(offspring1) {
+		offspring1 = p1.derive()
+	} else {
+		// polygons are shared with parents, make them independent
+		offspring1 = offspring1.clone()
+	}
+	if !valid(offspring2) {
+		offspring2 = p2.derive()
+	} else {
+		offspring2 = offspring2.clone()
+	}
+	return []framework.Candidate{offspring1, offspring2}
+}
+
+// spatialCut is a horizontal or vertical line splitting an image in 2 regions.
+type spatialCut struct {
+	vertical bool
+	pos      int // x coordinate of vertical cuts, y coordinate of horizontal ones
+}
@@ -0,0 +1,154 @@
+package evolver
+
+import (
+	"image/color"
+	"math/rand"
+	"testing"
+)
+
+func TestCrossover(t *testing.T) {
+	rng := rand.New(rand.NewSource(99))
+
+	img1 := &ImageDNA{
+		Polys: []Poly{
+			Poly{Col: color.RGBA{A: 0}},
+			Poly{Col: color.RGBA{A: 1}},
+			Poly{Col: color.RGBA{A: 2}},
+			Poly{Col: color.RGBA{A: 3}},
+		},
+	}
+
+	img2 := &ImageDNA{
+		Polys: []Poly{
+			Poly{Col: color.RGBA{A: 4}},
+			Poly{Col: color.RGBA{A: 5}},
+		},
+	}
+	mater := imageDNAMater{}
+	result := mater.Mate(img1, img2, 1, rng)
+
+	offspring1 := result[0].(*ImageDNA)
+	offspring2 := result[1].(*ImageDNA)
+
+	if offspring1.Polys[0].Col.(color.RGBA).A != 0 {
+		t.Errorf("want offspring1.Polys[0].Col.A = 0")
+	}
+	if offspring1.Polys[1].Col.(color.RGBA).A != 4 {
+		t.Errorf("want offspring1.Polys[1].Col.A = 4")
+	}
+	if offspring1.Polys[2].Col.(color.RGBA).A != 2 {
+		t.Errorf("want offspring1.Polys[2].Col.A = 2")
+	}
+	if offspring1.Polys[3].Col.(color.RGBA).A != 3 {
+		t.Errorf("want offspring1.Polys[3].Col.A = 3")
+	}
+	if offspring2.Polys[0].Col.(color.RGBA).A != 1 {
+		t.Errorf("want offspring2.Polys[0].Col.A = 1")
+	}
+	if offspring2.Polys[1].Col.(color.RGBA).A != 5 {
+		t.Errorf("want offspring2.Polys[1].Col.A = 5")
+	}
+}
+
+func TestCrossoverSinglePolygon(t *testing.T) {
+	rng := rand.New(rand.NewSource(99))
+	img1 := &ImageDNA{Polys: []Poly{{Col: color.RGBA{A: 0}}}}
+	img2 := &ImageDNA{Polys: []Poly{{Col: color.RGBA{A: 1}}, {Col: color.RGBA{A: 2}}, {Col: color.RGBA{A: 3}}}}
+	for _, parents := range [][2]*ImageDNA{{img1, img2}, {img2, img1}, {img1, img1}} {
+		result := imageDNAMater{}.Mate(parents[0], parents[1], 2, rng)
+		for i, c := range result {
+			if got := c.(*ImageDNA); len(got.Polys) != len(parents[i].Polys) {
+				t.Errorf("offspring %v has %v polygons, want %v", i, len(got.Polys), len(parents[i].Polys))
+			}
+		}
+	}
+}
+
+func TestSpatialCrossover(t *testing.T) {
+	rng := rand.New(rand.NewSource(99))
+
+	img1, img2 := &ImageDNA{W: 100, H: 100}, &ImageDNA{W: 100, H: 100}
+	for i := 0; i < 10; i++ {
+		img1.Polys = append(img1.Polys, randomPoly(img1, 3, 5, rng))
+	}
+	for i := 0; i < 6; i++ {
+		img2.Polys = append(img2.Polys, randomPoly(img2, 3, 5, rng))
+	}
+
+	mater := spatialMater{limits: Limits{MinPolys: 0, MaxPolys: 100, MinPoints: 3, MaxPoints: 5}}
+	result := mater.Mate(img1, img2, 2, rng)
+
+	// all polygons are given to exactly one offspring
+	n1, n2 := len(result[0].(*ImageDNA).Polys), len(result[1].(*ImageDNA).Polys)
+	if n1+n2 != 16 {
+		t.Errorf("want 16 polygons in offsprings, got %v + %v", n1, n2)
+	}
+}
+
+func TestUniformCrossover(t *testing.T) {
+	rng := rand.New(rand.NewSource(99))
+	limits := Limits{MinPolys: 1, MaxPolys: 10, MinPoints: 3, MaxPoints: 5}
+
+	// random parent, whose polygons are identified by their alpha, from a
+	// onwards
+	parent := func(a uint8) *ImageDNA {
+		img := &ImageDNA{W: 100, H: 100}
+		n := limits.MinPolys + rng.Intn(limits.MaxPolys-limits.MinPolys+1)
+		for i := 0; i < n; i++ {
+			p := randomPoly(img, limits.MinPoints, limits.MaxPoints, rng)
+			p.Col = color.RGBA{A: a + uint8(i)}
+			img.Polys = append(img.Polys, p)
+		}
+		return img
+	}
+
+	for i := 0; i < 100; i++ {
+		p1, p2 := parent(0), parent(100)
+		result := uniformMater{}.Mate(p1, p2, 1, rng)
+		o1, o2 := result[0].(*ImageDNA), result[1].(*ImageDNA)
+
+		for _, o := range []*ImageDNA{o1, o2} {
+			if len(o.Polys) < limits.MinPolys || len(o.Polys) > limits.MaxPolys {
+				t.Fatalf("offspring has %v polygons, want [%v, %v]", len(o.Polys), limits.MinPolys, limits.MaxPolys)
+			}
+			for _, p := range o.Polys {
+				if len(p.Pts) < limits.MinPoints || len(p.Pts) > limits.MaxPoints {
+					t.Fatalf("offspring polygon has %v points, want [%v, %v]", len(p.Pts), limits.MinPoints, limits.MaxPoints)
+				}
+			}
+		}
+		if len(o1.Polys) != len(p1.Polys) || len(o2.Polys) != len(p2.Polys) {
+			t.Fatalf("offsprings have %v and %v polygons, want %v and %v",
+				len(o1.Polys), len(o2.Polys), len(p1.Polys), len(p2.Polys))
+		}
+
+		// each polygon of offspring1 is either the one of parent1 at the same
+		// index, or swapped with the one of offspring2 at the same index of the
+		// aligned sub-sequences.
+		off, swapped := 0, false // offset of parent1 sub-sequence relative to parent2's
+		for k, p := range o1.Polys {
+			a := int(p.Col.(color.RGBA).A)
+			if a < 100 {
+				if a != k {
+					t.Fatalf("offspring1 polygon %v comes from parent1 polygon %v", k, a)
+				}
+				continue
+			}
+			j := a - 100
+			if !swapped {
+				off, swapped = k-j, true
+			}
+			if k-j != off {
+				t.Fatalf("offspring1 polygon %v comes from parent2 polygon %v, want %v", k, j, k-off)
+			}
+			if got := int(o2.Polys[j].Col.(color.RGBA).A); got != k {
+				t.Fatalf("offspring2 polygon %v comes from parent1 polygon %v, want %v", j, got, k)
+			}
+		}
+		for j, p := range o2.Polys {
+			if a := int(p.Col.(color.RGBA).A); a >= 100 && a-100 != j {
+				t.Fatalf("offspring2 polygon %v comes from parent2 polygon %v", j, a-100)
+			}
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package evolver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/aurelien-rainone/evolve/framework"
+)
+
+// dbName is the name of the evolution database in the output directory.
+const dbName = "evolution.db"
+
+// SchemaVersion is the version of the evolution database schema written by
+// this package. Tools reading the database should check the version recorded
+// in the schema_version table.
+const SchemaVersion = 3
+
+// migrations upgrade the evolution database schema, migrations[i] upgrades the
+// schema from version i to version i+1. Migrations must never be modified once
+// released, schema changes are made by appending new migrations.
+var migrations = [SchemaVersion]string{
+	// 1: generation statistics
+	`CREATE TABLE generations(
+		id INTEGER NOT NULL PRIMARY KEY,
+		best_fitness REAL NOT NULL,
+		mean_fitness REAL NOT NULL,
+		fitness_stddev REAL NOT NULL,
+		natural_fitness INTEGER NOT NULL,
+		pop_size INTEGER NOT NULL,
+		elite_count INTEGER NOT NULL,
+		gen_number INTEGER NOT NULL,
+		elapsed INTEGER NOT NULL);`,
+
+	// 2: genomes of the best candidates
+	`CREATE TABLE genomes(
+		id INTEGER NOT NULL PRIMARY KEY,
+		gen_number INTEGER NOT NULL,
+		rank INTEGER NOT NULL,
+		fitness REAL NOT NULL,
+		num_polys INTEGER NOT NULL,
+		num_points INTEGER NOT NULL,
+		genome TEXT NOT NULL);
+	CREATE INDEX genomes_gen_number ON genomes(gen_number);`,
+
+	// 3: run metadata, a database may hold several runs
+	`CREATE TABLE runs(
+		id INTEGER NOT NULL PRIMARY KEY,
+		started_at TEXT NOT NULL,
+		seed INTEGER NOT NULL,
+		ref_image TEXT NOT NULL,
+		config TEXT NOT NULL);
+	ALTER TABLE generations ADD COLUMN run_id INTEGER REFERENCES runs(id);
+	ALTER TABLE genomes ADD COLUMN run_id INTEGER REFERENCES runs(id);`,
+}
+
+const (
+	insertRunStr = `INSERT INTO runs(
+		started_at,
+		seed,
+		ref_image,
+		config)
+		values(?, ?, ?, ?)`
+
+	insertGenerationStr = `INSERT INTO generations(
+		run_id,
+		best_fitness,
+		mean_fitness,
+		fitness_stddev,
+		natural_fitness,
+		pop_size,
+		elite_count,
+		gen_number,
+		elapsed)
+		values(?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	insertGenomeStr = `INSERT INTO genomes(
+		run_id,
+		gen_number,
+		rank,
+		fitness,
+		num_polys,
+		num_points,
+		genome)
+		values(?, ?, ?, ?, ?, ?, ?)`
+
+	// genome of the best candidate of a generation, of the last run
+	selectGenomeStr = `SELECT genome FROM genomes WHERE gen_number = ? AND rank = 0
+		ORDER BY id DESC LIMIT 1`
+
+	// genomes of the best candidates of all generations of the last run
+	selectBestGenomesStr = `SELECT gen_number, fitness, genome FROM genomes
+		WHERE rank = 0 AND run_id IS (SELECT MAX(run_id) FROM genomes)
+		ORDER BY gen_number`
+)
+
+// schemaVersion returns the schema version of the database, creating the
+// schema_version table if it doesn't exist.
+func schemaVersion(ctx context.Context, conn *sql.Conn) (int, error) {
+	var version int
+	err := conn.QueryRowContext(ctx, "SELECT version FROM schema_version").Scan(&version)
+	if err == nil {
+		return version, nil
+	}
+
+	// databases written before schema versioning have no schema_version
+	// table, their version is deduced from the tables they have
+	tables := make(map[string]bool)
+	rows, err := conn.QueryContext(ctx, "SELECT name FROM sqlite_master WHERE type = 'table'")
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return 0, err
+		}
+		tables[name] = true
+	}
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+	switch {
+	case tables["schema_version"]:
+		return 0, fmt.Errorf("schema_version table is empty")
+	case tables["genomes"]:
+		version = 2
+	case tables["generations"]:
+		version = 1
+	}
+
+	if _, err = conn.ExecContext(ctx, "CREATE TABLE schema_version(version INTEGER NOT NULL)"); err != nil {
+		return 0, err
+	}
+	_, err = conn.ExecContext(ctx, "INSERT INTO schema_version(version) VALUES(?)", version)
+	return version, err
+}
+
+// migrate upgrades the database schema to SchemaVersion.
+func migrate(ctx context.Context, conn *sql.Conn) error {
+	version, err := schemaVersion(ctx, conn)
+	if err != nil {
+		return fmt.Errorf("can't read database schema version: %v", err)
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("database schema version %v is newer than supported version %v", version, SchemaVersion)
+	}
+
+	for ; version < SchemaVersion; version++ {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.Exec(migrations[version]); err == nil {
+			_, err = tx.Exec("UPDATE schema_version SET version = ?", version+1)
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("can't migrate database to version %v: %v", version+1, err)
+		}
+		if err = tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runMetadata describes an evolution run, as recorded in the runs table.
+type runMetadata struct {
+	start    time.Time
+	seed     int64
+	refImage string  // path of the reference image
+	config   Options // configuration of the run
+}
+
+// insertRun inserts the run described by meta into the runs table, and
+// returns its id.
+func insertRun(ctx context.Context, conn *sql.Conn, meta runMetadata) (int64, error) {
+	config, err := json.Marshal(meta.config)
+	if err != nil {
+		return 0, fmt.Errorf("can't encode configuration: %v", err)
+	}
+	res, err := conn.ExecContext(ctx, insertRunStr,
+		meta.start.Format(time.RFC3339), meta.seed, meta.refImage, string(config))
+	if err != nil {
+		return 0, fmt.Errorf("can't insert run: %v", err)
+	}
+	return res.LastInsertId()
+}
+
+// numPoints returns the total number of vertices of img.
+func (img *ImageDNA) numPoints() int {
+	n := 0
+	for _, p := range img.Polys {
+		n += len(p.Pts)
+	}
+	return n
+}
+
+// cachedFitness returns the fitness of img, if it has been evaluated.
+func (img *ImageDNA) cachedFitness() (float64, bool) {
+	img.mu.Lock()
+	defer img.mu.Unlock()
+	if img.cache == nil {
+		return 0, false
+	}
+	return img.cache.fitness, true
+}
+
+// elite returns the n fittest evaluated candidates of pop, best first, best
+// being the fittest candidate of pop.
+func elite(best *ImageDNA, pop []framework.Candidate, n int) []*ImageDNA {
+	type scored struct {
+		img     *ImageDNA
+		fitness float64
+	}
+	var cands []scored
+	for _, c := range pop {
+		img := c.(*ImageDNA)
+		if img == best {
+			continue
+		}
+		if f, ok := img.cachedFitness(); ok {
+			cands = append(cands, scored{img, f})
+		}
+	}
+	sort.SliceStable(cands, func(i, j int) bool { return cands[i].fitness < cands[j].fitness })
+
+	imgs := []*ImageDNA{best}
+	for i := 0; i < len(cands) && len(imgs) < n; i++ {
+		imgs = append(imgs, cands[i].img)
+	}
+	return imgs
+}
+
+// insertGenomes inserts into the genomes table of tx the genomes of imgs, the
+// evaluated candidates of generation genNum of run runID, ranked by fitness.
+func insertGenomes(tx *sql.Tx, runID int64, genNum int, imgs []*ImageDNA) error {
+	stmt, err := tx.Prepare(insertGenomeStr)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for rank, img := range imgs {
+		buf, err := json.Marshal(img)
+		if err != nil {
+			return fmt.Errorf("can't encode genome: %v", err)
+		}
+		fitness, _ := img.cachedFitness()
+		_, err = stmt.Exec(runID, genNum, rank, fitness, len(img.Polys), img.numPoints(), string(buf))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// openReadOnly opens the existing evolution database of dir, read-only.
+func openReadOnly(dir string) (*sql.DB, error) {
+	dbPath := path.Join(dir, dbName)
+	if _, err := os.Stat(dbPath); err != nil {
+		return nil, fmt.Errorf("can't open sqlite db: %v", err)
+	}
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?mode=ro")
+	if err != nil {
+		return nil, fmt.Errorf("can't open sqlite db: %v", err)
+	}
+	return db, nil
+}
+
+// ReadGenome reads the best candidate of the given generation from the
+// evolution database of dir.
+func ReadGenome(dir string, generation int) (*ImageDNA, error) {
+	db, err := openReadOnly(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	var buf string
+	err = db.QueryRow(selectGenomeStr, generation).Scan(&buf)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil, fmt.Errorf("no genome recorded for generation %v", generation)
+	case err != nil:
+		return nil, fmt.Errorf("can't read genome: %v", err)
+	}
+	img := &ImageDNA{}
+	if err = json.Unmarshal([]byte(buf), img); err != nil {
+		return nil, fmt.Errorf("can't decode genome: %v", err)
+	}
+	return img, nil
+}
+
+// readBestGenomes calls fn with the best candidate of each generation recorded
+// in the evolution database of dir, for the last run, in generation order.
+func readBestGenomes(dir string, fn func(generation int, fitness float64, img *ImageDNA) error) error {
+	db, err := openReadOnly(dir)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(selectBestGenomesStr)
+	if err != nil {
+		return fmt.Errorf("can't read genomes: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			generation int
+			fitness    float64
+			buf        string
+		)
+		if err = rows.Scan(&generation, &fitness, &buf); err != nil {
+			return fmt.Errorf("can't read genome: %v", err)
+		}
+		img := &ImageDNA{}
+		if err = json.Unmarshal([]byte(buf), img); err != nil {
+			return fmt.Errorf("can't decode genome of generation %v: %v", generation, err)
+		}
+		if err = fn(generation, fitness, img); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,188 @@
+package evolver
+
+import (
+	"context"
+	"database/sql"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aurelien-rainone/evolve/framework"
+)
+
+func TestElite(t *testing.T) {
+	pop := make([]framework.Candidate, 5)
+	for i, f := range []float64{4, 1, 3, 0, 2} {
+		pop[i] = &ImageDNA{W: 1, H: 1, cache: &renderCache{fitness: f}}
+	}
+	// an unevaluated candidate is never part of the elite
+	pop = append(pop, &ImageDNA{W: 1, H: 1})
+
+	best := pop[3].(*ImageDNA)
+	imgs := elite(best, pop, 3)
+	want := []*ImageDNA{best, pop[1].(*ImageDNA), pop[4].(*ImageDNA)}
+	if len(imgs) != len(want) {
+		t.Fatalf("got %v candidates, want %v", len(imgs), len(want))
+	}
+	for i := range want {
+		if imgs[i] != want[i] {
+			t.Errorf("candidate %v: got %p, want %p", i, imgs[i], want[i])
+		}
+	}
+
+	if imgs := elite(best, pop, 0); len(imgs) != 1 || imgs[0] != best {
+		t.Errorf("without elite, got %v candidates, want only the best", len(imgs))
+	}
+}
+
+func TestGenomes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "genomes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := sql.Open("sqlite3", path.Join(dir, dbName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if err = migrate(context.Background(), conn); err != nil {
+		t.Fatal(err)
+	}
+
+	img := &ImageDNA{
+		W: 20, H: 10,
+		Polys: []Poly{
+			{Col: color.NRGBA{R: 255, A: 51}, Pts: []image.Point{{0, 0}, {5, 1}, {2, 8}}},
+			{Col: color.NRGBA{G: 255, A: 20}, Pts: []image.Point{{1, 1}, {9, 1}, {9, 9}, {1, 9}}},
+		},
+		cache: &renderCache{fitness: 12},
+	}
+	other := &ImageDNA{W: 20, H: 10, Polys: img.Polys[:1], cache: &renderCache{fitness: 13}}
+
+	tx, err := conn.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = insertGenomes(tx, 1, 100, []*ImageDNA{img, other}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	var nPolys, nPoints int
+	var fitness float64
+	err = conn.QueryRowContext(context.Background(), "SELECT num_polys, num_points, fitness FROM genomes WHERE gen_number = 100 AND rank = 0").
+		Scan(&nPolys, &nPoints, &fitness)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nPolys != 2 || nPoints != 7 || fitness != 12 {
+		t.Errorf("got %v polygons, %v points, fitness %v, want 2, 7, 12", nPolys, nPoints, fitness)
+	}
+
+	got, err := ReadGenome(dir, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.W != img.W || got.H != img.H || len(got.Polys) != len(img.Polys) || got.numPoints() != img.numPoints() {
+		t.Errorf("got genome %+v, want %+v", got, img)
+	}
+
+	if _, err = ReadGenome(dir, 200); err == nil {
+		t.Errorf("want error reading an unrecorded generation")
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := sql.Open("sqlite3", path.Join(dir, dbName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// database written before schema versioning
+	if _, err = conn.ExecContext(ctx, migrations[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.ExecContext(ctx, `INSERT INTO generations(best_fitness, mean_fitness,
+		fitness_stddev, natural_fitness, pop_size, elite_count, gen_number, elapsed)
+		values(1, 2, 3, 0, 10, 1, 100, 5)`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = migrate(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+	version, err := schemaVersion(ctx, conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != SchemaVersion {
+		t.Errorf("got schema version %v, want %v", version, SchemaVersion)
+	}
+
+	// existing data is kept, new runs are appended
+	var opts Options
+	opts.Population.NumIndividuals = 7
+	runID, err := insertRun(ctx, conn, runMetadata{start: time.Now(), seed: 42, refImage: "ref.png", config: opts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = conn.ExecContext(ctx, insertGenerationStr, runID, 1, 2, 3, 0, 10, 1, 100, 5); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err = conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM generations").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("got %v generations, want 2", n)
+	}
+	var seed int64
+	var config string
+	err = conn.QueryRowContext(ctx, "SELECT seed, config FROM runs WHERE id = ?", runID).Scan(&seed, &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seed != 42 || !strings.Contains(config, `"NumIndividuals":7`) {
+		t.Errorf("got seed %v, config %v", seed, config)
+	}
+
+	// migrating an up to date database is a no-op
+	if err = migrate(ctx, conn); err != nil {
+		t.Fatal(err)
+	}
+
+	// databases written by newer versions are rejected
+	if _, err = conn.ExecContext(ctx, "UPDATE schema_version SET version = ?", SchemaVersion+1); err != nil {
+		t.Fatal(err)
+	}
+	if err = migrate(ctx, conn); err == nil {
+		t.Errorf("want error migrating a newer database")
+	}
+}
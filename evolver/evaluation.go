@@ -0,0 +1,90 @@
+package evolver
+
+import (
+	"fmt"
+	"image"
+	"runtime"
+
+	"github.com/aurelien-rainone/evolve/framework"
+)
+
+// FitnessEvaluator evaluates the fitness of candidates by comparing their
+// rendering with a reference image, the lesser the fitness the better.
+type FitnessEvaluator struct {
+	img    *image.RGBA // reference image
+	metric imageMetric // distance between reference and rendered candidates
+}
+
+// NewFitnessEvaluator creates a fitness evaluator comparing candidates with
+// the reference image img, using the image metric identified by metric. If
+// mask is not nil, it's the importance mask weighting the error of each pixel
+// (see ImportanceMask), it must have the size of img.
+func NewFitnessEvaluator(img *image.RGBA, metric string, mask *image.Gray) (*FitnessEvaluator, error) {
+	if mask != nil && mask.Bounds().Size() != img.Bounds().Size() {
+		return nil, fmt.Errorf("importance mask size %v doesn't match reference image size %v",
+			mask.Bounds().Size(), img.Bounds().Size())
+	}
+	weights, err := pixelWeights(mask)
+	if err != nil {
+		return nil, err
+	}
+	m, err := newImageMetric(metric, img, weights)
+	if err != nil {
+		return nil, err
+	}
+	return &FitnessEvaluator{img: img, metric: m}, nil
+}
+
+func abs(x int64) int64 {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func (fe *FitnessEvaluator) Fitness(c framework.Candidate, pop []framework.Candidate) float64 {
+	// compare the rendered chromosome to the reference image
+	return c.(*ImageDNA).evaluate(fe.metric).fitness
+}
+
+func (fe *FitnessEvaluator) IsNatural() bool {
+	// the lesser the fitness the better
+	return false
+}
+
+// boundedEvaluator is a fitness evaluator that limits the number of concurrent
+// fitness evaluations, while delegating fitness evaluation to another
+// evaluator.
+//
+// The evolution engine evaluates candidates concurrently, each evaluation
+// rendering a full image, limiting the number of evaluations running at the
+// same time bounds CPU and memory usage. As fitness only depends on the
+// candidate, concurrent evaluation doesn't affect the determinism of a run.
+type boundedEvaluator struct {
+	framework.FitnessEvaluator
+	sem chan struct{} // one token per running evaluation
+}
+
+// newBoundedEvaluator creates a fitness evaluator running at most workers
+// evaluations of fe concurrently. If workers is 0, it runs one evaluation per
+// CPU core.
+func newBoundedEvaluator(fe framework.FitnessEvaluator, workers int) (*boundedEvaluator, error) {
+	if workers < 0 {
+		return nil, fmt.Errorf("invalid number of workers %v", workers)
+	}
+	if workers == 0 {
+		workers = runtime.NumCPU()
+	}
+	return &boundedEvaluator{FitnessEvaluator: fe, sem: make(chan struct{}, workers)}, nil
+}
+
+func (be *boundedEvaluator) Fitness(c framework.Candidate, pop []framework.Candidate) float64 {
+	be.sem <- struct{}{}
+	defer func() { <-be.sem }()
+	return be.FitnessEvaluator.Fitness(c, pop)
+}
+
+// workers returns the maximum number of concurrent evaluations.
+func (be *boundedEvaluator) workers() int {
+	return cap(be.sem)
+}
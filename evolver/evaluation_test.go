@@ -0,0 +1,136 @@
+package evolver
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aurelien-rainone/evolve/framework"
+)
+
+// concurrencyEvaluator is a fitness evaluator recording the peak number of
+// concurrent evaluations.
+type concurrencyEvaluator struct {
+	running, peak int32
+}
+
+func (e *concurrencyEvaluator) Fitness(c framework.Candidate, pop []framework.Candidate) float64 {
+	n := atomic.AddInt32(&e.running, 1)
+	defer atomic.AddInt32(&e.running, -1)
+	for {
+		peak := atomic.LoadInt32(&e.peak)
+		if n <= peak || atomic.CompareAndSwapInt32(&e.peak, peak, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+	return 0
+}
+
+func (e *concurrencyEvaluator) IsNatural() bool { return false }
+
+func TestBoundedEvaluator(t *testing.T) {
+	for _, workers := range []int{1, 3} {
+		fe := &concurrencyEvaluator{}
+		be, err := newBoundedEvaluator(fe, workers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 30; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				be.Fitness(nil, nil)
+			}()
+		}
+		wg.Wait()
+		if fe.peak != int32(workers) {
+			t.Errorf("%v workers: got a peak of %v concurrent evaluations", workers, fe.peak)
+		}
+	}
+
+	if _, err := newBoundedEvaluator(&concurrencyEvaluator{}, -1); err == nil {
+		t.Errorf("want error for a negative number of workers")
+	}
+}
+
+// testOptions returns the options of a short evolution run, without observers.
+func testOptions() Options {
+	var opts Options
+	opts.Seed = 7
+	opts.Population.NumIndividuals = 10
+	opts.Population.EliteCount = 2
+	opts.Selection.Strategy = SelectionTournament
+	opts.Selection.Tournament.Size = 2
+	opts.Selection.Tournament.Probability = 0.7
+	opts.Image.MinPolys, opts.Image.MaxPolys = 2, 6
+	opts.Polygon.MinPoints, opts.Polygon.MaxPoints = 3, 5
+	opts.Fitness.Metric = MetricMSE
+	opts.Crossover.Operator = CrossoverUnequal
+	opts.Crossover.Probability = 0.7
+	opts.Crossover.Points = 1
+	opts.Mutation.Mode = MutationRandom
+	opts.Mutation.Image.AddPoly = 0.1
+	opts.Mutation.Image.RemovePoly = 0.1
+	opts.Mutation.Image.SwapPolys = 0.1
+	opts.Mutation.Polygon.AddPoint = 0.1
+	opts.Mutation.Polygon.RemovePoint = 0.1
+	opts.Mutation.Polygon.ChangeColor = 0.1
+	opts.Mutation.Point.Move = 0.1
+	opts.Termination.Generations = 20
+	return opts
+}
+
+// testReference returns a reference image made of two colored halves.
+func testReference() *image.RGBA {
+	ref := image.NewRGBA(image.Rect(0, 0, 32, 24))
+	for y := 0; y < 24; y++ {
+		for x := 0; x < 32; x++ {
+			c := color.RGBA{R: 200, G: 40, B: 40, A: 255}
+			if x >= 16 {
+				c = color.RGBA{R: 30, G: 60, B: 220, A: 255}
+			}
+			ref.Set(x, y, c)
+		}
+	}
+	return ref
+}
+
+func TestRunDeterminism(t *testing.T) {
+	opts := testOptions()
+	opts.Fitness.Workers = 4
+	var results []*Result
+	for i := 0; i < 2; i++ {
+		res, err := Run(context.Background(), testReference(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results = append(results, res)
+	}
+	if results[0].Generation != 19 {
+		t.Fatalf("got %v generations, want 20", results[0].Generation+1)
+	}
+	if results[0].Fitness != results[1].Fitness || results[0].Generation != results[1].Generation {
+		t.Errorf("got fitness %v at generation %v, then %v at generation %v",
+			results[0].Fitness, results[0].Generation, results[1].Fitness, results[1].Generation)
+	}
+	if !reflect.DeepEqual(results[0].Best.clone(), results[1].Best.clone()) {
+		t.Errorf("runs with the same seed have different best candidates")
+	}
+
+	// a different seed gives a different run
+	opts.Seed++
+	res, err := Run(context.Background(), testReference(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(res.Best.clone(), results[0].Best.clone()) {
+		t.Errorf("runs with different seeds have the same best candidate")
+	}
+}
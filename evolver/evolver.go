@@ -0,0 +1,556 @@
+// Package evolver evolves images made of semi-transparent polygons toward a
+// reference image, using a genetic algorithm.
+//
+// Candidate images are coded by ImageDNA genomes. Run drives a whole evolution,
+// configured by Options, while the factory, mutation, crossover, selection and
+// fitness evaluation operators are also exposed individually so that they can
+// be plugged into a custom evolution engine.
+package evolver
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"path"
+	"time"
+
+	"github.com/aurelien-rainone/evolve"
+	"github.com/aurelien-rainone/evolve/framework"
+	"github.com/aurelien-rainone/evolve/operators"
+)
+
+// Options configures an evolution run.
+type Options struct {
+	// RefImage is the path of the reference image. Run is given the decoded
+	// image, the path is only recorded in the evolution database.
+	RefImage string
+
+	// Seed of the pseudo random number generator, runs with the same seed,
+	// configuration and reference image are identical. 0 means a random seed.
+	Seed int64
+
+	Population struct {
+		// number of individuals in the population
+		NumIndividuals int `required:"true"`
+		// number of candidates preserved through elitism
+		EliteCount int `required:"true"`
+	}
+
+	Selection struct {
+		// Strategy is the name of the selection strategy: identity,
+		// tournament, roulette, rank, truncation or sigma
+		Strategy string `default:"tournament"`
+
+		Tournament struct {
+			// Size is the number of contestants of each tournament
+			Size int `default:"2"`
+			// Probability (0.5, 1] that the fittest contestant wins
+			Probability float64 `default:"0.7"`
+		}
+
+		Truncation struct {
+			// Ratio (0, 1] of the fittest candidates that are selected
+			Ratio float64 `default:"0.5"`
+		}
+	}
+
+	Image struct {
+		// MinPolys is the minimum number of polygon in an image
+		MinPolys int `required:"true"`
+		// MaxPolys is the minimum number of polygon in an image
+		MaxPolys int `required:"true"`
+	}
+
+	Polygon struct {
+		// MinPoints is the minimum number of points in a polygon
+		MinPoints int `required:"true"`
+		// MaxPoints is the maximum number of points in a polygon
+		MaxPoints int `required:"true"`
+	}
+
+	// Shapes gives the relative frequency of each shape among the random
+	// shapes of the images. If all are 0, only polygons are created.
+	Shapes struct {
+		Polygon  float64
+		Triangle float64
+		Circle   float64
+		Ellipse  float64
+		Rect     float64 // axis-aligned rectangle
+		RotRect  float64 // rotated rectangle
+		Bezier   float64 // closed cubic Bézier curve
+	}
+
+	Output struct {
+		// Format of the saved images: png or jpeg
+		Format string `default:"png"`
+		// Quality [1, 100] of jpeg images
+		Quality int `default:"90"`
+	}
+
+	// Observe configures the built-in evolution observers
+	Observe struct {
+		// OutDir is the directory where observers write their files, created if
+		// it doesn't exist. If empty, no files are written, and observers
+		// writing files can't be enabled.
+		OutDir string
+
+		// Log prints generation statistics
+		Log struct {
+			Enabled   bool
+			Frequency int `default:"100"`
+		}
+
+		// Snapshot saves the best candidate as an image and as SVG
+		Snapshot struct {
+			Enabled   bool
+			Frequency int `default:"100"`
+			// Pattern is the fmt format of snapshot file names, without
+			// extension, given the generation number
+			Pattern string `default:"%d"`
+		}
+
+		// Database records statistics and genomes in the evolution database
+		Database struct {
+			Enabled   bool
+			Frequency int `default:"100"`
+			// Elite, if true, stores the genomes of the whole elite of each
+			// sampled generation, instead of only the best one
+			Elite bool
+		}
+
+		// Notify signals new generations to viewers connected to the unix
+		// socket of the output directory
+		Notify struct {
+			Enabled   bool
+			Frequency int `default:"100"`
+		}
+
+		// Checkpoint saves the whole population, to resume the evolution
+		Checkpoint struct {
+			Enabled   bool
+			Frequency int `default:"1000"`
+		}
+
+		// Timelapse assembles the best candidates into an animated GIF,
+		// saved when the evolution ends
+		Timelapse struct {
+			Enabled   bool
+			Frequency int `default:"100"`
+			// File is the name of the animation in the output directory
+			File string `default:"timelapse.gif"`
+			// Delay between frames, in milliseconds
+			Delay int `default:"100"`
+			// Scale factor applied to the frames
+			Scale float64 `default:"1"`
+			// Overlay prints the generation number and fitness on frames
+			Overlay bool
+			// MaxFrames caps the number of frames kept in memory, frames
+			// being decimated evenly once reached
+			MaxFrames int `default:"300"`
+		}
+
+		// HTTP serves evolution progress over HTTP
+		HTTP struct {
+			Enabled   bool
+			Frequency int `default:"10"`
+			// Addr is the address the server listens on, for example ":8080"
+			Addr string `default:":8080"`
+		}
+	} `yaml:"observers" json:"observers"`
+
+	// Pyramid evolves the population against downscaled reference images
+	// first, stage by stage from the coarsest, before evolving it at full
+	// resolution. Pyramid stages are skipped when resuming from a checkpoint.
+	Pyramid struct {
+		Stages []PyramidStage
+	}
+
+	// Termination configures when the evolution stops, at the first of the
+	// enabled conditions that is satisfied, or when interrupted
+	Termination struct {
+		// Generations is the maximum number of generations, 0 for no limit.
+		// Generations of resumed runs count from the checkpointed one
+		Generations int
+		// Time is the maximum wall-clock duration of the run, for example
+		// 30m or 2h, empty for no limit
+		Time string
+		// Fitness stops the evolution once the best fitness is at most this
+		// target
+		Fitness float64
+		// Stagnation stops the evolution once the best fitness hasn't
+		// improved for this number of generations, 0 to never stop on
+		// stagnation
+		Stagnation int
+		// Similarity stops the evolution once the best candidate is at least
+		// this similar to the reference image, in percent, 0 to disable
+		Similarity float64
+	}
+
+	Fitness struct {
+		// Metric is the name of the image distance used to compute fitness:
+		// mse, mae, ssim or deltae
+		Metric string `default:"mse"`
+		// Mask is the importance mask weighting the error of each pixel:
+		// the path of a grayscale image of the size of the reference image,
+		// lighter pixels being more important, or edges or saliency to
+		// compute it from the reference image. Empty means all pixels are
+		// equally important
+		Mask string
+		// Workers is the number of candidates evaluated concurrently, 0
+		// means one per CPU core
+		Workers int
+	}
+
+	Crossover struct {
+		// Operator is the name of the crossover operator: unequal, uniform or
+		// spatial
+		Operator string `default:"unequal"`
+		// Probability [0, 1] that crossover is applied to a pair of parents
+		Probability float64 `default:"0.7"`
+		// Points is the number of crossover points (number of cuts for
+		// spatial crossover, ignored by uniform crossover)
+		Points int `default:"1"`
+	}
+
+	Mutation struct {
+		// Mode of the move-point and change-color mutations: random
+		// re-draws points and colors at random, gaussian nudges them by
+		// gaussian steps
+		Mode string `default:"random"`
+
+		// standard deviations of gaussian steps
+		Sigma struct {
+			// Point is the standard deviation of point moves, in pixels
+			Point float64 `default:"5"`
+			// Color is the standard deviation of color channel changes
+			Color float64 `default:"16"`
+			// Alpha is the standard deviation of alpha channel changes
+			Alpha float64 `default:"8"`
+			// Translate is the standard deviation of polygon translations,
+			// in pixels
+			Translate float64 `default:"5"`
+			// Scale is the standard deviation of polygon relative scale
+			// changes
+			Scale float64 `default:"0.1"`
+			// Rotate is the standard deviation of polygon rotations, in
+			// degrees
+			Rotate float64 `default:"10"`
+		}
+
+		// image level mutations
+		Image struct {
+			// Rate [0, 1] of add polygon mutation
+			AddPoly float64 `required:"true"`
+			// Rate [0, 1] of remove polygon mutation
+			RemovePoly float64 `required:"true"`
+			// Rate [0, 1] of swap polygon mutation
+			SwapPolys float64 `required:"true"`
+		}
+
+		// polygon level mutations
+		Polygon struct {
+			// Rate [0, 1] of add point mutation
+			AddPoint float64 `required:"true"`
+			// Rate [0, 1] of remove point mutation
+			RemovePoint float64 `required:"true"`
+			// Rate [0, 1] of change polygon color mutation
+			ChangeColor float64 `required:"true"`
+			// Rate [0, 1] of polygon translation
+			Translate float64
+			// Rate [0, 1] of polygon scaling
+			Scale float64
+			// Rate [0, 1] of polygon rotation
+			Rotate float64
+		}
+
+		// point level mutations
+		Point struct {
+			// Rate [0, 1] of move point mutation
+			Move float64 `required:"true"`
+		}
+	}
+
+	// Resume, if not nil, is the checkpoint from which the evolution resumes,
+	// instead of starting from a random population.
+	Resume *Checkpoint `yaml:"-" json:"-"`
+
+	// Observers are notified of each generation, in addition to the built-in
+	// observers enabled in Observe.
+	Observers []framework.EvolutionObserver `yaml:"-" json:"-"`
+}
+
+// Result is the outcome of an evolution run.
+type Result struct {
+	Best       *ImageDNA     // best candidate of the last generation
+	Fitness    float64       // fitness of the best candidate
+	Generation int           // number of the last generation
+	Elapsed    time.Duration // duration of the evolution
+
+	// termination conditions that stopped the evolution
+	Satisfied []framework.TerminationCondition
+}
+
+// Run evolves a population of ImageDNA toward the reference image ref, until
+// ctx is done or a termination condition of opts is satisfied, and returns the
+// best candidate.
+func Run(ctx context.Context, ref image.Image, opts Options) (*Result, error) {
+	start := time.Now()
+	img := ConvertToRGBA(ref)
+	var (
+		seed   = opts.Seed
+		offset int // generation offset
+	)
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	if ckpt := opts.Resume; ckpt != nil {
+		dna := ckpt.Population[0]
+		if dna.W != img.Bounds().Dx() || dna.H != img.Bounds().Dy() {
+			return nil, fmt.Errorf("checkpoint dimensions %v x %v don't match reference image %v x %v",
+				dna.W, dna.H, img.Bounds().Dx(), img.Bounds().Dy())
+		}
+		// shift the checkpointed seed so that the resumed run doesn't replay
+		// the random sequence of the original one
+		seed = ckpt.Seed + int64(ckpt.Generation)
+		offset = ckpt.Generation
+	}
+
+	// pseudo random number generator
+	rng := rand.New(rand.NewSource(seed))
+
+	// mutation settings
+	mutation, err := NewImageDNAMutation(opts.mutationParams())
+	if err != nil {
+		return nil, err
+	}
+
+	// crossover settings
+	crossover, err := NewImageDNACrossover(opts.crossoverParams())
+	if err != nil {
+		return nil, err
+	}
+
+	// create a pipeline that applies mutation then crossover
+	pipeline, err := operators.NewEvolutionPipeline(mutation, crossover)
+	if err != nil {
+		return nil, err
+	}
+
+	// define a selection strategy
+	selectionStrategy, err := NewSelectionStrategy(opts.selectionParams())
+	if err != nil {
+		return nil, err
+	}
+
+	// importance of each pixel of the reference image
+	mask, err := ImportanceMask(opts.Fitness.Mask, img)
+	if err != nil {
+		return nil, err
+	}
+
+	// define termination conditions
+	conds, err := opts.terminationConditions(ctx, start, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	// draft the population at lower resolutions first
+	if err = validatePyramid(opts.Pyramid.Stages); err != nil {
+		return nil, err
+	}
+	var seeds []framework.Candidate
+	if opts.Resume != nil {
+		seeds = opts.Resume.candidates(opts.Population.NumIndividuals)
+		if len(opts.Pyramid.Stages) > 0 {
+			log.Println("resuming from checkpoint, skipping pyramid stages")
+		}
+	} else if len(opts.Pyramid.Stages) > 0 {
+		seeds, err = evolvePyramid(ctx, start, img, mask, &opts, pipeline, selectionStrategy, rng)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	engine, evaluator, err := newEngine(img, mask, &opts, pipeline, selectionStrategy, rng)
+	if err != nil {
+		return nil, err
+	}
+
+	// define evolution observers
+	last := &lastObserver{}
+	engine.AddEvolutionObserver(last)
+	for _, o := range opts.Observers {
+		engine.AddEvolutionObserver(o)
+	}
+
+	log.Println("seed:", seed)
+	obs := &opts.Observe
+	if obs.OutDir == "" && (obs.Snapshot.Enabled || obs.Database.Enabled || obs.Notify.Enabled ||
+		obs.Checkpoint.Enabled || obs.Timelapse.Enabled) {
+		return nil, fmt.Errorf("snapshot, database, notify, checkpoint and timelapse observers require an output directory")
+	}
+	if obs.OutDir != "" {
+		log.Println("ouput directory:", obs.OutDir)
+		if err = os.MkdirAll(obs.OutDir, 0755); err != nil {
+			return nil, fmt.Errorf("can't create output directory: %v", err)
+		}
+
+		// log the seed, to be able to reproduce the run
+		err = ioutil.WriteFile(path.Join(obs.OutDir, "seed.txt"), []byte(fmt.Sprintln(seed)), 0644)
+		if err != nil {
+			return nil, fmt.Errorf("can't write seed: %v", err)
+		}
+
+		// save a copy of refernce image in output dir
+		saveToPng(path.Join(obs.OutDir, "_ref.png"), img)
+		if mask != nil {
+			saveToPng(path.Join(obs.OutDir, "_mask.png"), mask)
+		}
+	}
+
+	if obs.Log.Enabled {
+		logObs, err := newLogObserver(obs.Log.Frequency, offset)
+		if err != nil {
+			return nil, err
+		}
+		engine.AddEvolutionObserver(logObs)
+	}
+
+	var bestObs *bestObserver
+	if obs.Snapshot.Enabled {
+		bestObs, err = newBestObserver(obs.Snapshot.Frequency, obs.OutDir, obs.Snapshot.Pattern, opts.Output.Format, opts.Output.Quality, offset)
+		if err != nil {
+			return nil, err
+		}
+		engine.AddEvolutionObserver(bestObs)
+	}
+
+	if obs.Database.Enabled {
+		var eliteRecorder *populationRecorder
+		if obs.Database.Elite {
+			eliteRecorder = evaluator
+		}
+		meta := runMetadata{start: start, seed: seed, refImage: opts.RefImage, config: opts}
+		sqliteObs, err := newSqliteObserver(obs.Database.Frequency, obs.OutDir, eliteRecorder, meta, offset)
+		if err != nil {
+			return nil, err
+		}
+		defer sqliteObs.close()
+		engine.AddEvolutionObserver(sqliteObs)
+	}
+
+	if obs.Notify.Enabled {
+		// signal viewers of new generations, once their data has been saved
+		notifyObs, err := newNotifyObserver(obs.Notify.Frequency, path.Join(obs.OutDir, sockName), bestObs, offset)
+		if err != nil {
+			return nil, err
+		}
+		defer notifyObs.close()
+		engine.AddEvolutionObserver(notifyObs)
+	}
+
+	var ckptObs *checkpointObserver
+	if obs.Checkpoint.Enabled {
+		ckptObs, err = newCheckpointObserver(obs.Checkpoint.Frequency, obs.OutDir, evaluator, seed, offset)
+		if err != nil {
+			return nil, err
+		}
+		engine.AddEvolutionObserver(ckptObs)
+	}
+
+	if obs.Timelapse.Enabled {
+		tlObs, err := newTimelapseObserver(obs.Timelapse.Frequency, path.Join(obs.OutDir, obs.Timelapse.File),
+			TimelapseOptions{
+				Delay:     obs.Timelapse.Delay,
+				Scale:     obs.Timelapse.Scale,
+				Overlay:   obs.Timelapse.Overlay,
+				MaxFrames: obs.Timelapse.MaxFrames,
+			}, offset)
+		if err != nil {
+			return nil, err
+		}
+		defer tlObs.close()
+		engine.AddEvolutionObserver(tlObs)
+	}
+
+	if obs.HTTP.Enabled {
+		httpObs, err := NewHTTPObserver(obs.HTTP.Addr, obs.HTTP.Frequency, img)
+		if err != nil {
+			return nil, err
+		}
+		httpObs.offset = offset
+		defer httpObs.Close()
+		engine.AddEvolutionObserver(httpObs)
+	}
+
+	var best framework.Candidate
+	if seeds != nil {
+		best = engine.EvolveWithSeedCandidates(
+			opts.Population.NumIndividuals,
+			opts.Population.EliteCount,
+			seeds,
+			conds...)
+	} else {
+		best = engine.Evolve(
+			opts.Population.NumIndividuals,
+			opts.Population.EliteCount,
+			conds...)
+	}
+
+	if ckptObs != nil {
+		// checkpoint the final population
+		if err = ckptObs.save(best.(*ImageDNA)); err != nil {
+			log.Println("couldn't write checkpoint:", err)
+		}
+	}
+
+	satisfied, err := engine.SatisfiedTerminationConditions()
+	if err != nil {
+		return nil, err
+	}
+	res := &Result{Best: best.(*ImageDNA), Satisfied: satisfied}
+	if last.data != nil {
+		res.Fitness = last.data.BestCandidateFitness()
+		res.Generation = offset + last.data.GenerationNumber()
+		res.Elapsed = last.data.ElapsedTime()
+	}
+	return res, nil
+}
+
+// newEngine creates an evolution engine evolving images toward ref, using the
+// given operators. It also returns the fitness evaluator of the engine, that
+// records the evaluated population.
+func newEngine(ref *image.RGBA, mask *image.Gray, opts *Options, pipeline framework.EvolutionaryOperator,
+	selection framework.SelectionStrategy, rng *rand.Rand) (*evolve.GenerationalEvolutionEngine, *populationRecorder, error) {
+	// chromosome/image factory
+	DNAFactory, err := NewImageDNAFactory(ref.Bounds().Dx(), ref.Bounds().Dy(), opts.limits(), opts.shapeMix())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// define a fitness evaluator
+	fitness, err := NewFitnessEvaluator(ref, opts.Fitness.Metric, mask)
+	if err != nil {
+		return nil, nil, err
+	}
+	// spread evaluations over a pool of workers
+	bounded, err := newBoundedEvaluator(fitness, opts.Fitness.Workers)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Println("fitness evaluation workers:", bounded.workers())
+	// keep track of the population, for checkpointing
+	evaluator := &populationRecorder{FitnessEvaluator: bounded}
+
+	engine := evolve.NewGenerationalEvolutionEngine(DNAFactory,
+		pipeline,
+		evaluator,
+		selection,
+		rng)
+	engine.SetSingleThreaded(bounded.workers() == 1)
+	return engine, evaluator, nil
+}
@@ -0,0 +1,68 @@
+package evolver
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/aurelien-rainone/evolve/factory"
+	"github.com/aurelien-rainone/evolve/framework"
+)
+
+// ImageDNAFactory generates random ImageDNA candidates.
+type ImageDNAFactory struct {
+	factory.AbstractCandidateFactory
+}
+
+// NewImageDNAFactory creates a factory of candidates coding for imgW x imgH
+// images, with a number of polygons and points within limits, and shapes drawn
+// from the shapes mix.
+func NewImageDNAFactory(imgW, imgH int, limits Limits, shapes ShapeMix) (*ImageDNAFactory, error) {
+	if imgW == 0 || imgH == 0 {
+		return nil, fmt.Errorf("invalid dimensions %v x %v", imgW, imgH)
+	}
+	if err := limits.Validate(); err != nil {
+		return nil, err
+	}
+	if err := shapes.Validate(); err != nil {
+		return nil, err
+	}
+
+	sf := &ImageDNAFactory{
+		factory.AbstractCandidateFactory{
+			RandomCandidateGenerator: &imageDNAGenerator{
+				imgW:   imgW,
+				imgH:   imgH,
+				limits: limits,
+				shapes: shapes,
+			},
+		},
+	}
+	return sf, nil
+}
+
+type imageDNAGenerator struct {
+	imgW, imgH int      // width/height of the reference image
+	limits     Limits   // genome size limits
+	shapes     ShapeMix // frequency of each shape
+}
+
+func (g *imageDNAGenerator) GenerateRandomCandidate(rng *rand.Rand) framework.Candidate {
+	var numPolys int
+	if g.limits.MinPolys == g.limits.MaxPolys {
+		numPolys = g.limits.MaxPolys
+	} else {
+		numPolys = g.limits.MinPolys + rng.Intn(g.limits.MaxPolys-g.limits.MinPolys)
+	}
+
+	// create image dna with same dimensions than reference image
+	var img = &ImageDNA{
+		W:     g.imgW,
+		H:     g.imgH,
+		Polys: make([]Poly, numPolys),
+	}
+	// add N `numPolys` random polygons
+	for i := 0; i < numPolys; i++ {
+		img.Polys[i] = randomShape(img, g.shapes.pick(rng), g.limits.MinPoints, g.limits.MaxPoints, rng)
+	}
+	return img
+}
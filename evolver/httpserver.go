@@ -0,0 +1,281 @@
+package evolver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image"
+	"image/png"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aurelien-rainone/evolve/framework"
+)
+
+// generationStats are the statistics of a generation, as served to clients.
+type generationStats struct {
+	Generation     int     `json:"generation"`
+	BestFitness    float64 `json:"best_fitness"`
+	MeanFitness    float64 `json:"mean_fitness"`
+	FitnessStdDev  float64 `json:"fitness_stddev"`
+	NaturalFitness bool    `json:"natural_fitness"`
+	PopSize        int     `json:"pop_size"`
+	EliteCount     int     `json:"elite_count"`
+	Elapsed        float64 `json:"elapsed"` // in seconds
+}
+
+// newGenerationStats returns the statistics of data, generations being
+// numbered from offset.
+func newGenerationStats(data *framework.PopulationData, offset int) generationStats {
+	return generationStats{
+		Generation:     offset + data.GenerationNumber(),
+		BestFitness:    data.BestCandidateFitness(),
+		MeanFitness:    data.MeanFitness(),
+		FitnessStdDev:  data.FitnessStandardDeviation(),
+		NaturalFitness: data.IsNaturalFitness(),
+		PopSize:        data.PopulationSize(),
+		EliteCount:     data.EliteCount(),
+		Elapsed:        data.ElapsedTime().Seconds(),
+	}
+}
+
+// HTTPObserver is an evolution observer serving the progress of the evolution
+// over HTTP, so that it can be watched from a browser.
+//
+// It serves:
+//   - /: a page showing the reference image, the current best candidate and
+//     statistics, updated live
+//   - /api/stats: statistics of the last sampled generation, as JSON
+//   - /best.png: rendering of the best candidate of the last sampled generation
+//   - /ref.png: the reference image
+//   - /events: a Server-Sent Events stream, sending the statistics of each
+//     sampled generation
+type HTTPObserver struct {
+	freq   int // sample every N generations
+	offset int // generation number of the resumed checkpoint, set by Run
+	srv    *http.Server
+	ln     net.Listener
+	ref    []byte // PNG encoded reference image
+
+	mu      sync.Mutex
+	stats   *generationStats // last sampled generation, nil before the first one
+	best    *ImageDNA        // best candidate of the last sampled generation
+	bestPNG []byte           // PNG encoded best candidate, lazily rendered
+	clients map[chan []byte]struct{}
+}
+
+// NewHTTPObserver creates an HTTPObserver sampling the evolution every freq
+// generations, and starts serving on addr. ref is the reference image.
+func NewHTTPObserver(addr string, freq int, ref image.Image) (*HTTPObserver, error) {
+	if freq == 0 {
+		return nil, fmt.Errorf("HTTPObserver frequency can't be 0")
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, ref); err != nil {
+		return nil, fmt.Errorf("can't encode reference image: %v", err)
+	}
+
+	o := &HTTPObserver{
+		freq:    freq,
+		ref:     buf.Bytes(),
+		clients: make(map[chan []byte]struct{}),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", o.handleIndex)
+	mux.HandleFunc("/api/stats", o.handleStats)
+	mux.HandleFunc("/best.png", o.handleBest)
+	mux.HandleFunc("/ref.png", o.handleRef)
+	mux.HandleFunc("/events", o.handleEvents)
+	o.srv = &http.Server{Handler: mux}
+
+	var err error
+	if o.ln, err = net.Listen("tcp", addr); err != nil {
+		return nil, fmt.Errorf("can't listen on %v: %v", addr, err)
+	}
+	go func() {
+		if err := o.srv.Serve(o.ln); err != nil && err != http.ErrServerClosed {
+			log.Println("http server error:", err)
+		}
+	}()
+	log.Printf("serving evolution progress on http://%v\n", o.ln.Addr())
+	return o, nil
+}
+
+// Addr returns the address the observer is serving on.
+func (o *HTTPObserver) Addr() net.Addr {
+	return o.ln.Addr()
+}
+
+// Close stops the HTTP server and disconnects all clients.
+func (o *HTTPObserver) Close() error {
+	o.mu.Lock()
+	for c := range o.clients {
+		close(c)
+		delete(o.clients, c)
+	}
+	o.mu.Unlock()
+	return o.srv.Close()
+}
+
+// PopulationUpdate implements framework.EvolutionObserver.
+func (o *HTTPObserver) PopulationUpdate(data *framework.PopulationData) {
+	if (o.offset+data.GenerationNumber())%o.freq != 0 {
+		return
+	}
+
+	stats := newGenerationStats(data, o.offset)
+	msg, err := json.Marshal(stats)
+	if err != nil {
+		log.Println("can't encode generation stats:", err)
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.stats = &stats
+	o.best = data.BestCandidate().(*ImageDNA)
+	o.bestPNG = nil
+
+	// notify clients, dropping the event for those that are too slow
+	for c := range o.clients {
+		select {
+		case c <- msg:
+		default:
+		}
+	}
+}
+
+func (o *HTTPObserver) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	indexTmpl.Execute(w, nil)
+}
+
+func (o *HTTPObserver) handleStats(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	stats := o.stats
+	o.mu.Unlock()
+	if stats == nil {
+		http.Error(w, "no generation yet", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stats)
+}
+
+func (o *HTTPObserver) handleBest(w http.ResponseWriter, r *http.Request) {
+	o.mu.Lock()
+	best, buf := o.best, o.bestPNG
+	o.mu.Unlock()
+	if best == nil {
+		http.Error(w, "no generation yet", http.StatusServiceUnavailable)
+		return
+	}
+	if buf == nil {
+		var b bytes.Buffer
+		if err := png.Encode(&b, best.Render()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		buf = b.Bytes()
+
+		o.mu.Lock()
+		if o.best == best {
+			o.bestPNG = buf
+		}
+		o.mu.Unlock()
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(buf)
+}
+
+func (o *HTTPObserver) handleRef(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(o.ref)
+}
+
+func (o *HTTPObserver) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	c := make(chan []byte, 16)
+	o.mu.Lock()
+	o.clients[c] = struct{}{}
+	o.mu.Unlock()
+	defer func() {
+		o.mu.Lock()
+		delete(o.clients, c)
+		o.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	// keep the connection alive through proxies
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case msg, ok := <-c:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: generation\ndata: %s\n\n", msg)
+		case <-ticker.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+var indexTmpl = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>ARTificial</title>
+<style>
+body { font-family: sans-serif; }
+img { margin: 4px; border: 1px solid #ccc; }
+td { padding: 0 8px; }
+</style>
+</head>
+<body>
+<div><img src="ref.png" alt="reference"><img id="best" src="best.png" alt="best candidate"></div>
+<table>
+<tr><td>generation</td><td id="generation"></td></tr>
+<tr><td>best fitness</td><td id="best_fitness"></td></tr>
+<tr><td>mean fitness</td><td id="mean_fitness"></td></tr>
+<tr><td>fitness stddev</td><td id="fitness_stddev"></td></tr>
+<tr><td>elapsed (s)</td><td id="elapsed"></td></tr>
+</table>
+<script>
+function update(stats) {
+	for (const k of ["generation", "best_fitness", "mean_fitness", "fitness_stddev", "elapsed"]) {
+		document.getElementById(k).textContent = stats[k];
+	}
+	document.getElementById("best").src = "best.png?gen=" + stats.generation;
+}
+fetch("api/stats").then(r => r.ok ? r.json() : null).then(s => s && update(s));
+new EventSource("events").addEventListener("generation", e => update(JSON.parse(e.data)));
+</script>
+</body>
+</html>
+`))
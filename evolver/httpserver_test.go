@@ -0,0 +1,53 @@
+package evolver
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTPObserver(t *testing.T) {
+	ref := image.NewRGBA(image.Rect(0, 0, 4, 3))
+	ref.Set(1, 1, color.RGBA{R: 255, A: 255})
+
+	o, err := NewHTTPObserver("127.0.0.1:0", 10, ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer o.Close()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		o.srv.Handler.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		return rec
+	}
+
+	// no generation has been observed yet
+	for _, path := range []string{"/api/stats", "/best.png"} {
+		if rec := get(path); rec.Code != http.StatusServiceUnavailable {
+			t.Errorf("GET %v: got status %v, want %v", path, rec.Code, http.StatusServiceUnavailable)
+		}
+	}
+
+	rec := get("/ref.png")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /ref.png: got status %v", rec.Code)
+	}
+	img, err := png.Decode(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bounds() != ref.Bounds() {
+		t.Errorf("got reference bounds %v, want %v", img.Bounds(), ref.Bounds())
+	}
+	if r, _, _, _ := img.At(1, 1).RGBA(); r != 0xffff {
+		t.Errorf("got reference pixel %v, want red", img.At(1, 1))
+	}
+
+	if rec := get("/unknown"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /unknown: got status %v, want %v", rec.Code, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,65 @@
+package evolver
+
+import (
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"os"
+)
+
+// supported output image formats
+const (
+	OutputPNG  = "png"
+	OutputJPEG = "jpeg"
+)
+
+// SaveImage saves img in the given output format, into the file named base
+// followed by the format extension. quality is only used by the jpeg format.
+func SaveImage(base string, img image.Image, format string, quality int) error {
+	if format == OutputJPEG {
+		return saveToJpeg(base+imageExt(format), img, quality)
+	}
+	return saveToPng(base+imageExt(format), img)
+}
+
+// imageExt returns the file extension of the given output format.
+func imageExt(format string) string {
+	if format == OutputJPEG {
+		return ".jpg"
+	}
+	return ".png"
+}
+
+func saveToPng(fn string, img image.Image) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	if err = png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func saveToJpeg(fn string, img image.Image, quality int) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	if err = jpeg.Encode(f, img, &jpeg.Options{Quality: quality}); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ConvertToRGBA returns a copy of img as an *image.RGBA which bounds origin is
+// (0, 0).
+func ConvertToRGBA(img image.Image) *image.RGBA {
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	return rgba
+}
@@ -0,0 +1,44 @@
+package evolver
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestConvertToRGBA(t *testing.T) {
+	// images which bounds don't start at the origin, like sub-images
+	rgba := image.NewRGBA(image.Rect(0, 0, 20, 10))
+	rgba.Set(12, 6, color.RGBA{R: 255, A: 255})
+	gray := image.NewGray(image.Rect(0, 0, 20, 10))
+	gray.Set(12, 6, color.Gray{Y: 255})
+
+	tests := []struct {
+		name         string
+		img          image.Image
+		want, origin color.RGBA
+	}{
+		{"rgba", rgba.SubImage(image.Rect(10, 5, 20, 10)), color.RGBA{R: 255, A: 255}, color.RGBA{}},
+		{"gray", gray.SubImage(image.Rect(10, 5, 20, 10)), color.RGBA{R: 255, G: 255, B: 255, A: 255}, color.RGBA{A: 255}},
+	}
+	for _, tt := range tests {
+		got := ConvertToRGBA(tt.img)
+		if got.Bounds() != image.Rect(0, 0, 10, 5) {
+			t.Errorf("%v: got bounds %v, want (0,0)-(10,5)", tt.name, got.Bounds())
+			continue
+		}
+		if c := got.RGBAAt(2, 1); c != tt.want {
+			t.Errorf("%v: got pixel %v, want %v", tt.name, c, tt.want)
+		}
+		if c := got.RGBAAt(0, 0); c != tt.origin {
+			t.Errorf("%v: got pixel %v at origin, want %v", tt.name, c, tt.origin)
+		}
+	}
+
+	// the input image is copied
+	got := ConvertToRGBA(rgba)
+	got.Set(0, 0, color.White)
+	if rgba.RGBAAt(0, 0) != (color.RGBA{}) {
+		t.Errorf("ConvertToRGBA didn't copy its RGBA input")
+	}
+}
@@ -0,0 +1,187 @@
+package evolver
+
+import (
+	"image"
+	"image/draw"
+	"math"
+
+	"github.com/fogleman/gg"
+)
+
+// Most mutations only modify a few polygons, so that most of the rendered
+// image of a candidate is identical to the one of its parent. Candidates
+// derived from an evaluated parent inherit its rendering and per-tile error,
+// and track the dirty region, covering the polygons that changed. At
+// evaluation, only the tiles overlapping the dirty region are re-rendered and
+// re-scored.
+
+// tileSize is the side, in pixels, of the square tiles on which the error of a
+// rendered candidate is tracked. It must be a multiple of ssimWindow, so that
+// SSIM windows never straddle 2 tiles.
+const tileSize = 4 * ssimWindow
+
+// maxDirtyRatio is the ratio of dirty tiles above which a candidate gets fully
+// re-rendered, rather than incrementally.
+const maxDirtyRatio = 0.5
+
+// tileGrid partitions a w x h image into square tiles.
+type tileGrid struct {
+	w, h   int // image dimensions
+	nx, ny int // number of tiles on each axis
+}
+
+func newTileGrid(w, h int) tileGrid {
+	return tileGrid{
+		w:  w,
+		h:  h,
+		nx: (w + tileSize - 1) / tileSize,
+		ny: (h + tileSize - 1) / tileSize,
+	}
+}
+
+// len returns the number of tiles.
+func (g tileGrid) len() int {
+	return g.nx * g.ny
+}
+
+// tile returns the bounds of the tile at column tx and row ty.
+func (g tileGrid) tile(tx, ty int) image.Rectangle {
+	return image.Rect(tx*tileSize, ty*tileSize, min((tx+1)*tileSize, g.w), min((ty+1)*tileSize, g.h))
+}
+
+// cover returns the range of tiles, as [x0, x1) and [y0, y1), overlapping r.
+func (g tileGrid) cover(r image.Rectangle) (x0, y0, x1, y1 int) {
+	r = r.Intersect(image.Rect(0, 0, g.w, g.h))
+	if r.Empty() {
+		return 0, 0, 0, 0
+	}
+	return r.Min.X / tileSize, r.Min.Y / tileSize,
+		(r.Max.X + tileSize - 1) / tileSize, (r.Max.Y + tileSize - 1) / tileSize
+}
+
+// renderCache holds the rendering of a candidate and its error, tile by tile.
+type renderCache struct {
+	img     *image.RGBA
+	errs    []float64 // per-tile error
+	fitness float64   // sum of per-tile errors
+}
+
+// sum computes the fitness as the sum of the per-tile errors. The sum is
+// always performed in the same order, so that the fitness doesn't depend on
+// whether the candidate has been rendered fully or incrementally.
+func (rc *renderCache) sum() {
+	rc.fitness = 0
+	for _, e := range rc.errs {
+		rc.fitness += e
+	}
+}
+
+// derive returns a copy of img, inheriting its render cache if img has been
+// evaluated. The returned copy has an empty dirty region.
+func (img *ImageDNA) derive() *ImageDNA {
+	c := img.clone()
+	img.mu.Lock()
+	c.parent = img.cache
+	img.mu.Unlock()
+	return c
+}
+
+// touch marks the region covered by p as dirty, p being a polygon that has been
+// (or will be) added, removed or modified.
+func (img *ImageDNA) touch(p *Poly) {
+	img.dirty = img.dirty.Union(p.footprint())
+}
+
+// bounds returns the bounding box of the pixels the polygon may cover,
+// including antialiasing.
+func (p *Poly) bounds() image.Rectangle {
+	if len(p.Pts) == 0 {
+		return image.Rectangle{}
+	}
+	x0, y0, x1, y1 := p.extent()
+	// account for partially covered pixels
+	return image.Rect(int(math.Floor(x0))-1, int(math.Floor(y0))-1, int(math.Ceil(x1))+2, int(math.Ceil(y1))+2)
+}
+
+// footprint returns the region of the rendered image that the polygon may
+// modify. It's the polygon bounds, except for polygons crossing the top edge:
+// the rasterizer truncates negative coordinates towards zero, so that such
+// polygons may leak on the first row of pixels, up to the right edge.
+func (p *Poly) footprint() image.Rectangle {
+	r := p.bounds()
+	if r.Min.Y < 0 {
+		r.Max.X = math.MaxInt32
+	}
+	return r
+}
+
+// renderRegion re-renders the region r of dst, by only drawing the polygons
+// whose footprint overlaps r, the others not covering it. Polygons are drawn on a canvas
+// of the size of the image, as Render does, so that the rasterizer produces
+// the same pixels.
+func (img *ImageDNA) renderRegion(dst *image.RGBA, r image.Rectangle) {
+	tmp := image.NewRGBA(image.Rect(0, 0, img.W, img.H))
+	dc := gg.NewContextForRGBA(tmp)
+	dc.SetLineWidth(0)
+
+	for i := 0; i < len(img.Polys); i++ {
+		if img.Polys[i].footprint().Overlaps(r) {
+			drawPoly(dc, &img.Polys[i], 1, 1)
+		}
+	}
+	draw.Draw(dst, r, tmp, r.Min, draw.Src)
+}
+
+// evaluate returns the render cache of img, rendering and scoring it with
+// metric, fully or incrementally, if it's not been evaluated yet.
+func (img *ImageDNA) evaluate(metric imageMetric) *renderCache {
+	img.mu.Lock()
+	defer img.mu.Unlock()
+	if img.cache != nil {
+		return img.cache
+	}
+
+	grid := newTileGrid(img.W, img.H)
+	x0, y0, x1, y1 := grid.cover(img.dirty)
+	parent := img.parent
+
+	switch {
+	case parent != nil && x0 == x1:
+		// nothing changed since parent
+		img.cache = parent
+
+	case parent != nil && float64((x1-x0)*(y1-y0)) <= maxDirtyRatio*float64(grid.len()):
+		// only re-render and re-score dirty tiles
+		rc := &renderCache{
+			img:  image.NewRGBA(parent.img.Rect),
+			errs: make([]float64, len(parent.errs)),
+		}
+		copy(rc.img.Pix, parent.img.Pix)
+		copy(rc.errs, parent.errs)
+
+		dirty := grid.tile(x0, y0).Union(grid.tile(x1-1, y1-1))
+		img.renderRegion(rc.img, dirty)
+		for ty := y0; ty < y1; ty++ {
+			for tx := x0; tx < x1; tx++ {
+				rc.errs[ty*grid.nx+tx] = metric.regionError(rc.img, grid.tile(tx, ty))
+			}
+		}
+		rc.sum()
+		img.cache = rc
+
+	default:
+		rc := &renderCache{img: img.Render(), errs: make([]float64, grid.len())}
+		for ty := 0; ty < grid.ny; ty++ {
+			for tx := 0; tx < grid.nx; tx++ {
+				rc.errs[ty*grid.nx+tx] = metric.regionError(rc.img, grid.tile(tx, ty))
+			}
+		}
+		rc.sum()
+		img.cache = rc
+	}
+
+	// release the parent rendering
+	img.parent = nil
+	img.dirty = image.Rectangle{}
+	return img.cache
+}
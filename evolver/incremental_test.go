@@ -0,0 +1,113 @@
+package evolver
+
+import (
+	"bytes"
+	"image"
+	"math/rand"
+	"testing"
+)
+
+// smallShape returns a random shape of kind s, at most about 30 pixels wide,
+// anywhere on img and possibly across its borders.
+func smallShape(img *ImageDNA, s Shape, rng *rand.Rand) Poly {
+	p := randomShape(&ImageDNA{W: 30, H: 30}, s, 3, 6, rng)
+	d := image.Pt(rng.Intn(img.W+20)-25, rng.Intn(img.H+20)-25)
+	for i := range p.Pts {
+		p.Pts[i] = p.Pts[i].Add(d)
+	}
+	return p
+}
+
+// incremental reports whether img, derived from an evaluated parent, is going
+// to be evaluated incrementally.
+func incremental(img *ImageDNA) bool {
+	grid := newTileGrid(img.W, img.H)
+	x0, y0, x1, y1 := grid.cover(img.dirty)
+	return img.parent != nil && x0 != x1 && float64((x1-x0)*(y1-y0)) <= maxDirtyRatio*float64(grid.len())
+}
+
+// checkIncremental checks that the incremental evaluation of img matches its
+// full rendering, pixel for pixel, and its full evaluation.
+func checkIncremental(t *testing.T, img *ImageDNA, metric imageMetric) bool {
+	t.Helper()
+	got := img.evaluate(metric)
+	if want := img.Render(); !bytes.Equal(got.img.Pix, want.Pix) {
+		t.Errorf("incremental rendering differs from the full rendering")
+		return false
+	}
+	if want := img.clone().evaluate(metric).fitness; got.fitness != want {
+		t.Errorf("incremental fitness = %v, full fitness = %v", got.fitness, want)
+		return false
+	}
+	return true
+}
+
+func TestIncrementalEvaluation(t *testing.T) {
+	rng := rand.New(rand.NewSource(99))
+
+	const w, h = 200, 150
+	ref := image.NewRGBA(image.Rect(0, 0, w, h))
+	rng.Read(ref.Pix)
+	metric, err := newImageMetric(MetricMSE, ref, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for s := Shape(0); s < numShapes; s++ {
+		parent := &ImageDNA{W: w, H: h}
+		for i := 0; i < 20; i++ {
+			parent.Polys = append(parent.Polys, smallShape(parent, s, rng))
+		}
+		parent.evaluate(metric)
+
+		var n int // number of incremental evaluations
+		for i := 0; i < 100; i++ {
+			// move a point of a random polygon
+			child := parent.derive()
+			poly := &child.Polys[rng.Intn(len(child.Polys))]
+			child.touch(poly)
+			poly.jitterPoint(child, rng.Intn(len(poly.Pts)), 3, rng)
+			child.touch(poly)
+
+			if incremental(child) {
+				n++
+			}
+			if !checkIncremental(t, child, metric) {
+				t.Fatalf("%v: mutation %v", s, i)
+			}
+		}
+		if n < 50 {
+			t.Errorf("%v: only %v incremental evaluations", s, n)
+		}
+	}
+}
+
+func TestIncrementalChain(t *testing.T) {
+	rng := rand.New(rand.NewSource(7))
+
+	const w, h = 120, 90
+	ref := image.NewRGBA(image.Rect(0, 0, w, h))
+	rng.Read(ref.Pix)
+	metric, err := newImageMetric(MetricMSE, ref, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each generation derives from the previous one, inheriting its
+	// rendering, so that errors would accumulate
+	img := &ImageDNA{W: w, H: h}
+	for i := 0; i < 30; i++ {
+		img.Polys = append(img.Polys, smallShape(img, Shape(i)%numShapes, rng))
+	}
+	img.evaluate(metric)
+	for gen := 0; gen < 1000; gen++ {
+		child := img.derive()
+		idx := rng.Intn(len(child.Polys))
+		child.touch(&child.Polys[idx])
+		child.Polys[idx] = smallShape(child, Shape(rng.Intn(int(numShapes))), rng)
+		child.touch(&child.Polys[idx])
+		child.evaluate(metric)
+		img = child
+	}
+	checkIncremental(t, img, metric)
+}
@@ -0,0 +1,189 @@
+package evolver
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"math"
+)
+
+// names of the automatic importance masks, as they appear in the
+// configuration
+const (
+	MaskEdges    = "edges"    // emphasizes the edges of the reference image
+	MaskSaliency = "saliency" // emphasizes the salient regions of the reference image
+)
+
+// autoMaskFloor is the lowest weight of automatic masks, relative to the
+// weight of the most important pixels, so that unimportant regions still
+// contribute to fitness.
+const autoMaskFloor = 0.2
+
+// ImportanceMask returns the importance mask of the reference image ref, that
+// weights the error of each pixel in fitness: the lighter a pixel of the mask,
+// the more important the corresponding pixel of the reference image.
+//
+// spec is either the name of an automatic mask, computed from ref, or the path
+// of a grayscale image of the same size as ref. ImportanceMask returns nil if
+// spec is empty, meaning all pixels are equally important.
+func ImportanceMask(spec string, ref *image.RGBA) (*image.Gray, error) {
+	b := ref.Bounds()
+	switch spec {
+	case "":
+		return nil, nil
+	case MaskEdges:
+		return autoMask(edgeMap(ref), b), nil
+	case MaskSaliency:
+		return autoMask(saliencyMap(ref), b), nil
+	}
+
+	img, err := readImage(spec)
+	if err != nil {
+		return nil, fmt.Errorf("can't read importance mask: %v", err)
+	}
+	if img.Bounds().Size() != b.Size() {
+		return nil, fmt.Errorf("importance mask %v is %v, want the size of the reference image %v",
+			spec, img.Bounds().Size(), b.Size())
+	}
+	mask := image.NewGray(b)
+	draw.Draw(mask, b, img, img.Bounds().Min, draw.Src)
+	return mask, nil
+}
+
+// pixelWeights converts an importance mask into per-pixel weights, in row
+// order, normalized so that their mean is 1. It returns nil if mask is nil.
+func pixelWeights(mask *image.Gray) ([]float64, error) {
+	if mask == nil {
+		return nil, nil
+	}
+	b := mask.Bounds()
+	w, h := b.Dx(), b.Dy()
+	weights := make([]float64, w*h)
+	var sum float64
+	for y := 0; y < h; y++ {
+		off := y * mask.Stride
+		for x := 0; x < w; x++ {
+			weights[y*w+x] = float64(mask.Pix[off+x])
+			sum += weights[y*w+x]
+		}
+	}
+	if sum == 0 {
+		return nil, fmt.Errorf("importance mask is all black")
+	}
+	scale := float64(len(weights)) / sum
+	for i := range weights {
+		weights[i] *= scale
+	}
+	return weights, nil
+}
+
+// autoMask converts a map of importance, in row order, into a mask with
+// autoMaskFloor as lowest weight.
+func autoMask(vals []float64, b image.Rectangle) *image.Gray {
+	var maxv float64
+	for _, v := range vals {
+		maxv = math.Max(maxv, v)
+	}
+	mask := image.NewGray(b)
+	w := b.Dx()
+	for i, v := range vals {
+		if maxv > 0 {
+			v /= maxv
+		}
+		mask.Pix[(i/w)*mask.Stride+i%w] = uint8(math.Round(255 * (autoMaskFloor + (1-autoMaskFloor)*v)))
+	}
+	return mask
+}
+
+// blurRadius returns the radius of the blur applied to importance maps, so
+// that the neighbourhood of important pixels is important too.
+func blurRadius(w, h int) int {
+	return max(1, min(w, h)/64)
+}
+
+// edgeMap returns the gradient magnitude of the luma of img, computed with a
+// Sobel operator on the slightly blurred image, then spread out.
+func edgeMap(img *image.RGBA) []float64 {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	lum := boxBlur(luma(img), w, h, 1)
+	at := func(x, y int) float64 {
+		x = max(0, min(w-1, x))
+		y = max(0, min(h-1, y))
+		return lum[y*w+x]
+	}
+
+	edges := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			gx := at(x+1, y-1) + 2*at(x+1, y) + at(x+1, y+1) -
+				at(x-1, y-1) - 2*at(x-1, y) - at(x-1, y+1)
+			gy := at(x-1, y+1) + 2*at(x, y+1) + at(x+1, y+1) -
+				at(x-1, y-1) - 2*at(x, y-1) - at(x+1, y-1)
+			edges[y*w+x] = math.Hypot(gx, gy)
+		}
+	}
+	return boxBlur(edges, w, h, blurRadius(w, h))
+}
+
+// saliencyMap returns the frequency-tuned saliency of img (Achanta et al.
+// 2009): the CIELAB distance of each pixel of the blurred image to the mean
+// color of the image.
+func saliencyMap(img *image.RGBA) []float64 {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	var chans [3][]float64
+	for c := range chans {
+		chans[c] = make([]float64, w*h)
+	}
+	var mean [3]float64
+	for y := 0; y < h; y++ {
+		off := y * img.Stride
+		for x := 0; x < w; x++ {
+			c := rgbToLab(img.Pix[off], img.Pix[off+1], img.Pix[off+2])
+			chans[0][y*w+x], chans[1][y*w+x], chans[2][y*w+x] = c.l, c.a, c.b
+			mean[0] += c.l
+			mean[1] += c.a
+			mean[2] += c.b
+			off += 4
+		}
+	}
+	for c := range chans {
+		mean[c] /= float64(w * h)
+		chans[c] = boxBlur(chans[c], w, h, blurRadius(w, h))
+	}
+
+	sal := make([]float64, w*h)
+	for i := range sal {
+		var d float64
+		for c := range chans {
+			d += (chans[c][i] - mean[c]) * (chans[c][i] - mean[c])
+		}
+		sal[i] = math.Sqrt(d)
+	}
+	return sal
+}
+
+// boxBlur returns vals, a w*h map in row order, blurred with a square box of
+// radius r. Pixels outside of the map are ignored.
+func boxBlur(vals []float64, w, h, r int) []float64 {
+	blur1D := func(src, dst []float64, n, stride int) {
+		for i := 0; i < n; i++ {
+			var sum float64
+			lo, hi := max(0, i-r), min(n-1, i+r)
+			for j := lo; j <= hi; j++ {
+				sum += src[j*stride]
+			}
+			dst[i*stride] = sum / float64(hi-lo+1)
+		}
+	}
+	tmp := make([]float64, len(vals))
+	for y := 0; y < h; y++ {
+		blur1D(vals[y*w:], tmp[y*w:], w, 1)
+	}
+	out := make([]float64, len(vals))
+	for x := 0; x < w; x++ {
+		blur1D(tmp[x:], out[x:], h, w)
+	}
+	return out
+}
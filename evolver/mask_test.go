@@ -0,0 +1,88 @@
+package evolver
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"testing"
+)
+
+func uniformMask(w, h int, v uint8) *image.Gray {
+	mask := image.NewGray(image.Rect(0, 0, w, h))
+	draw.Draw(mask, mask.Bounds(), &image.Uniform{color.Gray{v}}, image.ZP, draw.Src)
+	return mask
+}
+
+func TestUniformMask(t *testing.T) {
+	// a uniform mask, whatever its level, gives the unweighted distance
+	ref := uniformImage(20, 12, color.RGBA{R: 120, G: 30, B: 200, A: 255})
+	img := uniformImage(20, 12, color.RGBA{R: 100, G: 80, B: 10, A: 255})
+	img.Set(3, 4, color.RGBA{A: 255})
+	weights, err := pixelWeights(uniformMask(20, 12, 77))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{MetricMSE, MetricMAE, MetricSSIM, MetricDeltaE} {
+		m, _ := newImageMetric(name, ref, nil)
+		wm, _ := newImageMetric(name, ref, weights)
+		d, wd := m.regionError(img, img.Bounds()), wm.regionError(img, img.Bounds())
+		if math.Abs(d-wd) > 1e-9*d {
+			t.Errorf("%s: got weighted distance %v, want %v", name, wd, d)
+		}
+	}
+
+	if _, err := pixelWeights(uniformMask(4, 4, 0)); err == nil {
+		t.Errorf("want error for an all black mask")
+	}
+}
+
+func TestWeightedMetric(t *testing.T) {
+	// the left half of the image is important, the right half isn't
+	ref := uniformImage(16, 16, color.RGBA{A: 255})
+	mask := uniformMask(16, 16, 0)
+	draw.Draw(mask, image.Rect(0, 0, 8, 16), &image.Uniform{color.Gray{255}}, image.ZP, draw.Src)
+	weights, err := pixelWeights(mask)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	left, right := uniformImage(16, 16, color.RGBA{A: 255}), uniformImage(16, 16, color.RGBA{A: 255})
+	draw.Draw(left, image.Rect(2, 2, 6, 6), &image.Uniform{color.White}, image.ZP, draw.Src)
+	draw.Draw(right, image.Rect(10, 2, 14, 6), &image.Uniform{color.White}, image.ZP, draw.Src)
+	for _, name := range []string{MetricMSE, MetricMAE, MetricSSIM, MetricDeltaE} {
+		m, _ := newImageMetric(name, ref, weights)
+		dl, dr := m.regionError(left, left.Bounds()), m.regionError(right, right.Bounds())
+		if dl <= 0 || dr != 0 {
+			t.Errorf("%s: got distance %v for an important error, %v for an unimportant one", name, dl, dr)
+		}
+	}
+}
+
+func TestAutoMask(t *testing.T) {
+	// white square on a black background
+	ref := uniformImage(64, 64, color.RGBA{A: 255})
+	draw.Draw(ref, image.Rect(24, 24, 40, 40), &image.Uniform{color.White}, image.ZP, draw.Src)
+
+	for _, spec := range []string{MaskEdges, MaskSaliency} {
+		mask, err := ImportanceMask(spec, ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mask.Bounds() != ref.Bounds() {
+			t.Fatalf("%s: got mask bounds %v, want %v", spec, mask.Bounds(), ref.Bounds())
+		}
+		// the background corner is the least important, yet not ignored
+		bg, sq := mask.GrayAt(2, 2).Y, mask.GrayAt(24, 32).Y
+		if bg == 0 || bg >= sq {
+			t.Errorf("%s: got background weight %v, square weight %v", spec, bg, sq)
+		}
+	}
+
+	if mask, err := ImportanceMask("", ref); mask != nil || err != nil {
+		t.Errorf("want no mask without spec, got %v, %v", mask, err)
+	}
+	if _, err := NewFitnessEvaluator(ref, MetricMSE, uniformMask(10, 10, 255)); err == nil {
+		t.Errorf("want error for a mask of the wrong size")
+	}
+}
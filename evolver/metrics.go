@@ -0,0 +1,370 @@
+package evolver
+
+import (
+	"fmt"
+	"image"
+	"math"
+)
+
+// imageMetric computes the distance between a reference image and a rendered
+// candidate image of the same dimensions.
+//
+// A distance of 0 means both images are identical, the greater the distance
+// the more different they are.
+//
+// The distance is computed region by region, regionError returning the
+// contribution of the pixels of r to the distance, so that the distance of a
+// partially re-rendered image can be updated incrementally. The distance of
+// the whole image is the sum of the errors of the tiles partitioning it (see
+// tileGrid).
+type imageMetric interface {
+	regionError(img *image.RGBA, r image.Rectangle) float64
+}
+
+// names of the available image metrics, as they appear in the configuration
+const (
+	MetricMSE    = "mse"    // per-channel mean squared error
+	MetricMAE    = "mae"    // per-channel mean absolute error
+	MetricSSIM   = "ssim"   // structural dissimilarity (1 - SSIM)
+	MetricDeltaE = "deltae" // mean CIE76 delta-E in CIELAB color space
+)
+
+// newImageMetric creates the image metric identified by name, comparing images
+// against ref. weights, if not nil, are the per-pixel weights of the error, in
+// row order and of mean 1 (see pixelWeights).
+func newImageMetric(name string, ref *image.RGBA, weights []float64) (imageMetric, error) {
+	switch name {
+	case MetricMSE, "":
+		return newMSEMetric(ref, weights), nil
+	case MetricMAE:
+		return newMAEMetric(ref, weights), nil
+	case MetricSSIM:
+		return newSSIMMetric(ref, weights), nil
+	case MetricDeltaE:
+		return newDeltaEMetric(ref, weights), nil
+	}
+	return nil, fmt.Errorf("unknown fitness metric %q", name)
+}
+
+// maxDistances are, for each metric, the distances between an image and the
+// most different one, used to express distances as similarities. MSE
+// distances are converted to RMSE first, so that similarity is linear in the
+// color differences, as it is for the other metrics.
+var maxDistances = map[string]float64{
+	MetricMSE:    255,
+	"":           255,
+	MetricMAE:    255,
+	MetricSSIM:   1,
+	MetricDeltaE: 100,
+}
+
+// Similarity converts fitness, a distance computed with the image metric
+// identified by metric, into a similarity percentage: 100 for identical
+// images, 0 for images as different as possible.
+func Similarity(metric string, fitness float64) float64 {
+	d, ok := maxDistances[metric]
+	if !ok {
+		return 0
+	}
+	if metric == MetricMSE || metric == "" {
+		fitness = math.Sqrt(fitness)
+	}
+	return 100 * math.Max(0, 1-fitness/d)
+}
+
+// mseMetric is the mean of the squared differences of each color channel.
+type mseMetric struct {
+	ref     *image.RGBA
+	weights []float64 // per-pixel weights, nil if uniform
+	norm    float64   // normalization factor
+}
+
+func newMSEMetric(ref *image.RGBA, weights []float64) *mseMetric {
+	b := ref.Bounds()
+	return &mseMetric{ref: ref, weights: weights, norm: 1 / float64(3*b.Dx()*b.Dy())}
+}
+
+func (m *mseMetric) regionError(img *image.RGBA, r image.Rectangle) float64 {
+	if m.weights != nil {
+		return weightedError(m.ref, img, r, m.weights, func(d int64) int64 { return d * d }) * m.norm
+	}
+	var sum int64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		roff, ioff := y*m.ref.Stride+r.Min.X*4, y*img.Stride+r.Min.X*4
+		for x := r.Min.X; x < r.Max.X; x++ {
+			for c := 0; c < 3; c++ {
+				d := int64(m.ref.Pix[roff+c]) - int64(img.Pix[ioff+c])
+				sum += d * d
+			}
+			roff += 4
+			ioff += 4
+		}
+	}
+	return float64(sum) * m.norm
+}
+
+// maeMetric is the mean of the absolute differences of each color channel.
+type maeMetric struct {
+	ref     *image.RGBA
+	weights []float64 // per-pixel weights, nil if uniform
+	norm    float64   // normalization factor
+}
+
+func newMAEMetric(ref *image.RGBA, weights []float64) *maeMetric {
+	b := ref.Bounds()
+	return &maeMetric{ref: ref, weights: weights, norm: 1 / float64(3*b.Dx()*b.Dy())}
+}
+
+func (m *maeMetric) regionError(img *image.RGBA, r image.Rectangle) float64 {
+	if m.weights != nil {
+		return weightedError(m.ref, img, r, m.weights, abs) * m.norm
+	}
+	var sum int64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		roff, ioff := y*m.ref.Stride+r.Min.X*4, y*img.Stride+r.Min.X*4
+		for x := r.Min.X; x < r.Max.X; x++ {
+			for c := 0; c < 3; c++ {
+				sum += abs(int64(m.ref.Pix[roff+c]) - int64(img.Pix[ioff+c]))
+			}
+			roff += 4
+			ioff += 4
+		}
+	}
+	return float64(sum) * m.norm
+}
+
+// weightedError returns the sum over the pixels of r of the errors of each
+// color channel, the error of a pixel being weighted by its weight. The error
+// of a channel is f of the channel difference.
+func weightedError(ref, img *image.RGBA, r image.Rectangle, weights []float64, f func(int64) int64) float64 {
+	w := ref.Bounds().Dx()
+	var sum float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		roff, ioff := y*ref.Stride+r.Min.X*4, y*img.Stride+r.Min.X*4
+		for x := r.Min.X; x < r.Max.X; x++ {
+			var e int64
+			for c := 0; c < 3; c++ {
+				e += f(int64(ref.Pix[roff+c]) - int64(img.Pix[ioff+c]))
+			}
+			sum += float64(e) * weights[y*w+x]
+			roff += 4
+			ioff += 4
+		}
+	}
+	return sum
+}
+
+// ssimWindow is the side, in pixels, of the square windows over which the
+// structural similarity is computed.
+const ssimWindow = 8
+
+// ssimMetric computes the structural dissimilarity (1 - SSIM) of the luma of
+// both images. SSIM is computed over non-overlapping square windows, aligned
+// on the image origin, then averaged over the whole image. With per-pixel
+// weights, the dissimilarity of a window is weighted by the mean weight of its
+// pixels.
+type ssimMetric struct {
+	w, h    int
+	ref     []float64 // reference image luma
+	weights []float64 // per-window weights, nil if uniform
+	norm    float64   // normalization factor
+}
+
+func newSSIMMetric(ref *image.RGBA, weights []float64) *ssimMetric {
+	b := ref.Bounds()
+	nwx, nwy := (b.Dx()+ssimWindow-1)/ssimWindow, (b.Dy()+ssimWindow-1)/ssimWindow
+	m := &ssimMetric{w: b.Dx(), h: b.Dy(), ref: luma(ref), norm: 1 / float64(nwx*nwy)}
+	if weights == nil {
+		return m
+	}
+
+	// mean pixel weight of each window
+	m.weights = make([]float64, nwx*nwy)
+	var sum float64
+	for wy := 0; wy < nwy; wy++ {
+		for wx := 0; wx < nwx; wx++ {
+			var ww float64
+			r := image.Rect(wx*ssimWindow, wy*ssimWindow, (wx+1)*ssimWindow, (wy+1)*ssimWindow).Intersect(b.Sub(b.Min))
+			for y := r.Min.Y; y < r.Max.Y; y++ {
+				for x := r.Min.X; x < r.Max.X; x++ {
+					ww += weights[y*m.w+x]
+				}
+			}
+			ww /= float64(r.Dx() * r.Dy())
+			m.weights[wy*nwx+wx] = ww
+			sum += ww
+		}
+	}
+	if sum > 0 {
+		m.norm = 1 / sum
+	}
+	return m
+}
+
+// regionError returns the dissimilarity of the windows of r, r must be aligned
+// on window boundaries.
+func (m *ssimMetric) regionError(img *image.RGBA, r image.Rectangle) float64 {
+	const (
+		c1 = (0.01 * 255) * (0.01 * 255)
+		c2 = (0.03 * 255) * (0.03 * 255)
+	)
+
+	var (
+		lum    [ssimWindow * ssimWindow]float64 // luma of the current window
+		total  float64
+		wx, wy int
+		nwx    = (m.w + ssimWindow - 1) / ssimWindow // number of windows per row
+	)
+	for y0 := r.Min.Y; y0 < r.Max.Y; y0 += ssimWindow {
+		wy = min(ssimWindow, r.Max.Y-y0)
+		for x0 := r.Min.X; x0 < r.Max.X; x0 += ssimWindow {
+			wx = min(ssimWindow, r.Max.X-x0)
+
+			// means
+			var mr, mi float64
+			for y := 0; y < wy; y++ {
+				off := (y0+y)*img.Stride + x0*4
+				for x := 0; x < wx; x++ {
+					l := 0.299*float64(img.Pix[off]) +
+						0.587*float64(img.Pix[off+1]) +
+						0.114*float64(img.Pix[off+2])
+					lum[y*ssimWindow+x] = l
+					mr += m.ref[(y0+y)*m.w+x0+x]
+					mi += l
+					off += 4
+				}
+			}
+			n := float64(wx * wy)
+			mr /= n
+			mi /= n
+
+			// variances and covariance
+			var vr, vi, cov float64
+			for y := 0; y < wy; y++ {
+				for x := 0; x < wx; x++ {
+					dr := m.ref[(y0+y)*m.w+x0+x] - mr
+					di := lum[y*ssimWindow+x] - mi
+					vr += dr * dr
+					vi += di * di
+					cov += dr * di
+				}
+			}
+			vr /= n
+			vi /= n
+			cov /= n
+
+			d := 1 - ((2*mr*mi+c1)*(2*cov+c2))/
+				((mr*mr+mi*mi+c1)*(vr+vi+c2))
+			if m.weights != nil {
+				d *= m.weights[(y0/ssimWindow)*nwx+x0/ssimWindow]
+			}
+			total += d
+		}
+	}
+	return total * m.norm
+}
+
+// luma returns the Rec. 601 luma of each pixel of img.
+func luma(img *image.RGBA) []float64 {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	lum := make([]float64, w*h)
+	for y := 0; y < h; y++ {
+		off := y * img.Stride
+		for x := 0; x < w; x++ {
+			lum[y*w+x] = 0.299*float64(img.Pix[off]) +
+				0.587*float64(img.Pix[off+1]) +
+				0.114*float64(img.Pix[off+2])
+			off += 4
+		}
+	}
+	return lum
+}
+
+// deltaEMetric is the mean CIE76 color difference (delta-E) between pixels of
+// both images, in the CIELAB color space.
+type deltaEMetric struct {
+	w, h    int
+	ref     []lab     // reference image converted to CIELAB
+	weights []float64 // per-pixel weights, nil if uniform
+	norm    float64   // normalization factor
+}
+
+func newDeltaEMetric(ref *image.RGBA, weights []float64) *deltaEMetric {
+	b := ref.Bounds()
+	m := &deltaEMetric{
+		w:       b.Dx(),
+		h:       b.Dy(),
+		ref:     make([]lab, b.Dx()*b.Dy()),
+		weights: weights,
+		norm:    1 / float64(b.Dx()*b.Dy()),
+	}
+	for y := 0; y < m.h; y++ {
+		off := y * ref.Stride
+		for x := 0; x < m.w; x++ {
+			m.ref[y*m.w+x] = rgbToLab(ref.Pix[off], ref.Pix[off+1], ref.Pix[off+2])
+			off += 4
+		}
+	}
+	return m
+}
+
+func (m *deltaEMetric) regionError(img *image.RGBA, r image.Rectangle) float64 {
+	var sum float64
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		off := y*img.Stride + r.Min.X*4
+		for x := r.Min.X; x < r.Max.X; x++ {
+			c := rgbToLab(img.Pix[off], img.Pix[off+1], img.Pix[off+2])
+			ref := m.ref[y*m.w+x]
+			d := math.Sqrt((ref.l-c.l)*(ref.l-c.l) + (ref.a-c.a)*(ref.a-c.a) + (ref.b-c.b)*(ref.b-c.b))
+			if m.weights != nil {
+				d *= m.weights[y*m.w+x]
+			}
+			sum += d
+			off += 4
+		}
+	}
+	return sum * m.norm
+}
+
+// lab is a color in the CIELAB color space.
+type lab struct {
+	l, a, b float64
+}
+
+// srgbToLinear maps 8-bit sRGB components to linear intensities in [0, 1].
+var srgbToLinear [256]float64
+
+func init() {
+	for i := range srgbToLinear {
+		c := float64(i) / 255
+		if c <= 0.04045 {
+			srgbToLinear[i] = c / 12.92
+		} else {
+			srgbToLinear[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+}
+
+// rgbToLab converts an sRGB color into CIELAB, using the D65 white point.
+func rgbToLab(r, g, b uint8) lab {
+	lr, lg, lb := srgbToLinear[r], srgbToLinear[g], srgbToLinear[b]
+
+	// linear RGB to XYZ, normalized by D65 reference white
+	x := (0.4124564*lr + 0.3575761*lg + 0.1804375*lb) / 0.95047
+	y := 0.2126729*lr + 0.7151522*lg + 0.0721750*lb
+	z := (0.0193339*lr + 0.1191920*lg + 0.9503041*lb) / 1.08883
+
+	f := func(t float64) float64 {
+		if t > 216.0/24389.0 {
+			return math.Cbrt(t)
+		}
+		return (24389.0/27.0*t + 16) / 116
+	}
+	fx, fy, fz := f(x), f(y), f(z)
+	return lab{
+		l: 116*fy - 16,
+		a: 500 * (fx - fy),
+		b: 200 * (fy - fz),
+	}
+}
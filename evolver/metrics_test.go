@@ -0,0 +1,48 @@
+package evolver
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func uniformImage(w, h int, col color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), &image.Uniform{col}, image.ZP, draw.Src)
+	return img
+}
+
+func TestImageMetricIdentical(t *testing.T) {
+	ref := uniformImage(16, 16, color.RGBA{R: 120, G: 30, B: 200, A: 255})
+	for _, name := range []string{MetricMSE, MetricMAE, MetricSSIM, MetricDeltaE} {
+		m, err := newImageMetric(name, ref, nil)
+		if err != nil {
+			t.Fatalf("newImageMetric(%q) error: %v", name, err)
+		}
+		if d := m.regionError(ref, ref.Bounds()); d > 1e-9 {
+			t.Errorf("%s: want distance of identical images = 0, got %v", name, d)
+		}
+	}
+}
+
+func TestImageMetricColorSensitive(t *testing.T) {
+	// red and green have the same R+G+B total, metrics must tell them apart
+	ref := uniformImage(16, 16, color.RGBA{R: 255, A: 255})
+	img := uniformImage(16, 16, color.RGBA{G: 255, A: 255})
+	for _, name := range []string{MetricMSE, MetricMAE, MetricDeltaE} {
+		m, err := newImageMetric(name, ref, nil)
+		if err != nil {
+			t.Fatalf("newImageMetric(%q) error: %v", name, err)
+		}
+		if d := m.regionError(img, img.Bounds()); d <= 0 {
+			t.Errorf("%s: want distance between red and green > 0, got %v", name, d)
+		}
+	}
+}
+
+func TestImageMetricUnknown(t *testing.T) {
+	if _, err := newImageMetric("foo", uniformImage(1, 1, color.Black), nil); err == nil {
+		t.Errorf("want error for unknown metric")
+	}
+}
@@ -0,0 +1,289 @@
+package evolver
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"math/rand"
+
+	"github.com/aurelien-rainone/evolve/framework"
+	"github.com/aurelien-rainone/evolve/number"
+	"github.com/aurelien-rainone/evolve/operators"
+	"github.com/fogleman/gg"
+)
+
+// mutation modes of points and colors
+const (
+	// MutationRandom re-draws moved points and changed colors at random.
+	MutationRandom = "random"
+	// MutationGaussian nudges moved points and changed colors by gaussian
+	// steps.
+	MutationGaussian = "gaussian"
+)
+
+// NewImageDNAMutation creates the mutation operator, configured with params.
+func NewImageDNAMutation(params MutationParams) (*operators.AbstractMutation, error) {
+	if err := params.Limits.Validate(); err != nil {
+		return nil, err
+	}
+	if err := params.Shapes.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch params.Mode {
+	case "", MutationRandom, MutationGaussian:
+	default:
+		return nil, fmt.Errorf("unknown mutation mode %q", params.Mode)
+	}
+	for _, sigma := range []float64{params.PointSigma, params.ColorSigma, params.AlphaSigma,
+		params.TranslateSigma, params.ScaleSigma, params.RotateSigma} {
+		if sigma < 0 {
+			return nil, fmt.Errorf("mutation standard deviations can't be negative, got %v", sigma)
+		}
+	}
+
+	// create and configure mutater with all mutation rates
+	mutater := &imageDNAMutater{
+		limits:         params.Limits,
+		shapes:         params.Shapes,
+		gaussian:       params.Mode == MutationGaussian,
+		pointSigma:     params.PointSigma,
+		colorSigma:     params.ColorSigma,
+		alphaSigma:     params.AlphaSigma,
+		translateSigma: params.TranslateSigma,
+		scaleSigma:     params.ScaleSigma,
+		rotateSigma:    params.RotateSigma,
+	}
+
+	var (
+		prob number.Probability
+		err  error
+	)
+
+	// set image-level mutations
+	if prob, err = number.NewProbability(params.AddPoly); err != nil {
+		return nil, fmt.Errorf("add-polygon mutation rate error: %v", err)
+	}
+	mutater.addPolygonMutation = number.NewConstantProbabilityGenerator(prob)
+
+	if prob, err = number.NewProbability(params.RemovePoly); err != nil {
+		return nil, fmt.Errorf("remove-polygon mutation rate error: %v", err)
+	}
+	mutater.removePolygonMutation = number.NewConstantProbabilityGenerator(prob)
+
+	if prob, err = number.NewProbability(params.SwapPolys); err != nil {
+		return nil, fmt.Errorf("swap-polygon mutation rate error: %v", err)
+	}
+	mutater.swapPolygonsMutation = number.NewConstantProbabilityGenerator(prob)
+
+	// set polygon-level mutations
+	if prob, err = number.NewProbability(params.AddPoint); err != nil {
+		return nil, fmt.Errorf("add-point mutation rate error: %v", err)
+	}
+	mutater.addPointMutation = number.NewConstantProbabilityGenerator(prob)
+
+	if prob, err = number.NewProbability(params.RemovePoint); err != nil {
+		return nil, fmt.Errorf("remove-point mutation rate error: %v", err)
+	}
+	mutater.removePointMutation = number.NewConstantProbabilityGenerator(prob)
+
+	if prob, err = number.NewProbability(params.ChangeColor); err != nil {
+		return nil, fmt.Errorf("change-polygon-color mutation rate error: %v", err)
+	}
+	mutater.changePolyColorMutation = number.NewConstantProbabilityGenerator(prob)
+
+	// set polygon transforms
+	if prob, err = number.NewProbability(params.Translate); err != nil {
+		return nil, fmt.Errorf("translate mutation rate error: %v", err)
+	}
+	mutater.translateMutation = number.NewConstantProbabilityGenerator(prob)
+
+	if prob, err = number.NewProbability(params.Scale); err != nil {
+		return nil, fmt.Errorf("scale mutation rate error: %v", err)
+	}
+	mutater.scaleMutation = number.NewConstantProbabilityGenerator(prob)
+
+	if prob, err = number.NewProbability(params.Rotate); err != nil {
+		return nil, fmt.Errorf("rotate mutation rate error: %v", err)
+	}
+	mutater.rotateMutation = number.NewConstantProbabilityGenerator(prob)
+
+	// set point-level mutations
+	if prob, err = number.NewProbability(params.MovePoint); err != nil {
+		return nil, fmt.Errorf("move-point mutation rate error: %v", err)
+	}
+	mutater.movePointMutation = number.NewConstantProbabilityGenerator(prob)
+
+	impl, err := operators.NewAbstractMutation(mutater)
+	mutater.impl = impl
+	return impl, err
+}
+
+type imageDNAMutater struct {
+	impl   *operators.AbstractMutation
+	limits Limits   // genome size limits
+	shapes ShapeMix // frequency of the shapes of added polygons
+
+	// image-level mutations
+	addPolygonMutation    number.ProbabilityGenerator
+	removePolygonMutation number.ProbabilityGenerator
+	swapPolygonsMutation  number.ProbabilityGenerator
+
+	// polygon-level mutations
+	addPointMutation        number.ProbabilityGenerator
+	removePointMutation     number.ProbabilityGenerator
+	changePolyColorMutation number.ProbabilityGenerator
+
+	// polygon transforms
+	translateMutation number.ProbabilityGenerator
+	scaleMutation     number.ProbabilityGenerator
+	rotateMutation    number.ProbabilityGenerator
+
+	// point-level mutations
+	movePointMutation number.ProbabilityGenerator
+
+	// standard deviations of local mutations
+	gaussian       bool    // nudge points and colors instead of re-drawing them
+	pointSigma     float64 // in pixels
+	colorSigma     float64 // color channel, out of 255
+	alphaSigma     float64 // alpha channel, out of 255
+	translateSigma float64 // in pixels
+	scaleSigma     float64 // relative scale
+	rotateSigma    float64 // in degrees
+}
+
+func (op *imageDNAMutater) Mutate(c framework.Candidate, rng *rand.Rand) framework.Candidate {
+	// mutates a copy of the image, mutation do not touch the original. Each
+	// modified polygon is marked dirty so that only the region it covers
+	// gets re-rendered at evaluation
+	img := c.(*ImageDNA).derive()
+
+	if op.addPolygonMutation.NextValue().NextEvent(rng) {
+		if len(img.Polys) < op.limits.MaxPolys {
+			// add a new random polygon
+			img.Polys = append(img.Polys,
+				randomShape(img, op.shapes.pick(rng), op.limits.MinPoints, op.limits.MaxPoints, rng))
+			img.touch(&img.Polys[len(img.Polys)-1])
+		}
+	}
+
+	if op.removePolygonMutation.NextValue().NextEvent(rng) {
+		if len(img.Polys) > op.limits.MinPolys {
+			// find removal index
+			idx := rng.Intn(len(img.Polys))
+			img.touch(&img.Polys[idx])
+			// split slice before and after, and append those 2 parts together
+			img.Polys = append(img.Polys[:idx], img.Polys[idx+1:]...)
+		}
+	}
+
+	if op.swapPolygonsMutation.NextValue().NextEvent(rng) {
+		// swap 2 random polygons
+		idx1, idx2 := rng.Intn(len(img.Polys)), rng.Intn(len(img.Polys))
+		if idx1 != idx2 {
+			img.touch(&img.Polys[idx1])
+			img.touch(&img.Polys[idx2])
+		}
+		img.Polys[idx1], img.Polys[idx2] = img.Polys[idx2], img.Polys[idx1]
+	}
+
+	for i := 0; i < len(img.Polys); i++ {
+		poly := &img.Polys[i]
+		// region covered by the polygon before mutation
+		before := poly.footprint()
+		mutated := false
+
+		if op.changePolyColorMutation.NextValue().NextEvent(rng) {
+			// change poly color
+			if op.gaussian {
+				poly.Col = nudgeColor(poly.Col, op.colorSigma, op.alphaSigma, rng)
+			} else {
+				poly.Col = randomColor(rng)
+			}
+			mutated = true
+		}
+
+		// whole polygon transforms, around the polygon center
+		if op.translateMutation.NextValue().NextEvent(rng) {
+			dx, dy := op.translateSigma*rng.NormFloat64(), op.translateSigma*rng.NormFloat64()
+			// keep the polygon center in the image
+			c := poly.pivot()
+			dx = f64Clip(dx, -c.X, float64(img.W)-c.X)
+			dy = f64Clip(dy, -c.Y, float64(img.H)-c.Y)
+			poly.transform(gg.Translate(dx, dy))
+			mutated = true
+		}
+
+		if op.scaleMutation.NextValue().NextEvent(rng) {
+			f := math.Max(0.1, 1+op.scaleSigma*rng.NormFloat64())
+			c := poly.pivot()
+			poly.transform(gg.Translate(c.X, c.Y).Scale(f, f).Translate(-c.X, -c.Y))
+			mutated = true
+		}
+
+		if op.rotateMutation.NextValue().NextEvent(rng) {
+			a := gg.Radians(op.rotateSigma * rng.NormFloat64())
+			c := poly.pivot()
+			poly.transform(gg.Translate(c.X, c.Y).Rotate(a).Translate(-c.X, -c.Y))
+			mutated = true
+		}
+
+		// only polygons and Bézier shapes have a variable number of points
+		variable := poly.Shape.numPoints() == 0
+
+		if op.addPointMutation.NextValue().NextEvent(rng) {
+			numPts := len(poly.Pts)
+			if variable && numPts < op.limits.MaxPoints {
+				// find insertion index
+				idx := 1 + rng.Intn(numPts-1)
+				// insert point at the middle of prev and next points
+				poly.insert(idx, poly.Pts[idx-1].Add(poly.Pts[idx]).Div(2))
+				mutated = true
+			}
+		}
+
+		if op.removePointMutation.NextValue().NextEvent(rng) {
+			numPts := len(poly.Pts)
+			if variable && numPts > op.limits.MinPoints {
+				// find removal index
+				idx := rng.Intn(numPts)
+				// split slice before and after, and append those 2 parts together
+				poly.Pts = append(poly.Pts[:idx], poly.Pts[idx+1:]...)
+				mutated = true
+			}
+		}
+
+		for j := 0; j < len(poly.Pts); j++ {
+			if op.movePointMutation.NextValue().NextEvent(rng) {
+				if op.gaussian {
+					poly.jitterPoint(img, j, op.pointSigma, rng)
+				} else {
+					poly.movePoint(img, j, rng)
+				}
+				mutated = true
+			}
+		}
+
+		if mutated {
+			img.dirty = img.dirty.Union(before)
+			img.touch(poly)
+		}
+	}
+
+	// returns cloned image, possibily mutated
+	return img
+}
+
+// nudgeColor returns c with a gaussian step of standard deviation sigma added
+// to each color channel, and of standard deviation alphaSigma added to alpha.
+func nudgeColor(c color.Color, sigma, alphaSigma float64, rng *rand.Rand) color.NRGBA {
+	nudge := func(b uint8, sigma float64) uint8 {
+		return uint8(f64Clip(math.Round(float64(b)+sigma*rng.NormFloat64()), 0, math.MaxUint8))
+	}
+	nc := color.NRGBAModel.Convert(c).(color.NRGBA)
+	nc.R = nudge(nc.R, sigma)
+	nc.G = nudge(nc.G, sigma)
+	nc.B = nudge(nc.B, sigma)
+	nc.A = nudge(nc.A, alphaSigma)
+	return nc
+}
@@ -0,0 +1,126 @@
+package evolver
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/aurelien-rainone/evolve/framework"
+	"github.com/fogleman/gg"
+)
+
+func TestNudgeColor(t *testing.T) {
+	rng := rand.New(rand.NewSource(5))
+	col := color.NRGBA{R: 250, G: 128, B: 3, A: 40}
+	var sum [4]float64
+	const n = 1000
+	for i := 0; i < n; i++ {
+		c := nudgeColor(col, 16, 4, rng)
+		sum[0] += float64(c.R) - float64(col.R)
+		sum[1] += float64(c.G) - float64(col.G)
+		sum[2] += float64(c.B) - float64(col.B)
+		sum[3] += math.Abs(float64(c.A) - float64(col.A))
+	}
+	// channels close to the bounds are clipped, others are nudged evenly
+	if sum[0] >= 0 || sum[2] <= 0 || math.Abs(sum[1]/n) > 2 {
+		t.Errorf("got mean color steps %v", sum[:3])
+	}
+	if mean := sum[3] / n; mean < 2 || mean > 5 {
+		t.Errorf("got mean absolute alpha step %v, want about 3.2", mean)
+	}
+}
+
+func TestPolyTransforms(t *testing.T) {
+	p := Poly{Pts: []image.Point{{10, 10}, {30, 10}, {30, 20}, {10, 20}}}
+	c := p.pivot()
+	if c != (gg.Point{X: 20, Y: 15}) {
+		t.Fatalf("got pivot %v, want (20, 15)", c)
+	}
+
+	p.transform(gg.Translate(c.X, c.Y).Rotate(gg.Radians(90)).Translate(-c.X, -c.Y))
+	want := []image.Point{{25, 5}, {25, 25}, {15, 25}, {15, 5}}
+	for i := range want {
+		if p.Pts[i] != want[i] {
+			t.Fatalf("after rotation, got %v, want %v", p.Pts, want)
+		}
+	}
+
+	p.transform(gg.Translate(c.X, c.Y).Scale(2, 2).Translate(-c.X, -c.Y))
+	want = []image.Point{{30, -5}, {30, 35}, {10, 35}, {10, -5}}
+	for i := range want {
+		if p.Pts[i] != want[i] {
+			t.Fatalf("after scaling, got %v, want %v", p.Pts, want)
+		}
+	}
+}
+
+func TestJitterPoint(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	img := &ImageDNA{W: 20, H: 20}
+
+	// points are kept in the image
+	p := Poly{Pts: []image.Point{{0, 0}, {20, 20}, {10, 10}}}
+	for i := 0; i < 100; i++ {
+		for j := range p.Pts {
+			p.jitterPoint(img, j, 10, rng)
+			if !p.Pts[j].In(image.Rect(0, 0, 21, 21)) {
+				t.Fatalf("point %v is out of the image", p.Pts[j])
+			}
+		}
+	}
+
+	// jittering the center of a circle translates it
+	circle := Poly{Shape: ShapeCircle, Pts: []image.Point{{10, 10}, {15, 10}}}
+	circle.jitterPoint(img, 0, 3, rng)
+	if d := circle.Pts[1].Sub(circle.Pts[0]); d != image.Pt(5, 0) {
+		t.Errorf("circle radius changed: %v", circle.Pts)
+	}
+
+	// and keeps its center in the image
+	for i := 0; i < 100; i++ {
+		circle.jitterPoint(img, 0, 50, rng)
+		if !circle.Pts[0].In(image.Rect(0, 0, 21, 21)) {
+			t.Fatalf("circle center %v is out of the image", circle.Pts[0])
+		}
+	}
+	if d := circle.Pts[1].Sub(circle.Pts[0]); d != image.Pt(5, 0) {
+		t.Errorf("circle radius changed: %v", circle.Pts)
+	}
+}
+
+func TestTranslateMutation(t *testing.T) {
+	rng := rand.New(rand.NewSource(4))
+	limits := Limits{MinPolys: 1, MaxPolys: 1, MinPoints: 3, MaxPoints: 3}
+	op, err := NewImageDNAMutation(MutationParams{Limits: limits, Translate: 1, TranslateSigma: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c framework.Candidate = &ImageDNA{
+		W: 40, H: 30,
+		Polys: []Poly{
+			{Col: color.NRGBA{A: 255}, Pts: []image.Point{{0, 0}, {10, 0}, {0, 10}}},
+			{Shape: ShapeCircle, Col: color.NRGBA{A: 255}, Pts: []image.Point{{20, 15}, {25, 15}}},
+		},
+	}
+	for i := 0; i < 100; i++ {
+		c = op.Apply([]framework.Candidate{c}, rng)[0]
+		for _, p := range c.(*ImageDNA).Polys {
+			// pivots are rounded with the translated points
+			if pv := p.pivot(); pv.X < -1 || pv.X > 41 || pv.Y < -1 || pv.Y > 31 {
+				t.Fatalf("polygon center %v is out of the image", pv)
+			}
+		}
+	}
+}
+
+func TestMutationParamsValidation(t *testing.T) {
+	limits := Limits{MinPolys: 1, MaxPolys: 2, MinPoints: 3, MaxPoints: 4}
+	if _, err := NewImageDNAMutation(MutationParams{Limits: limits, Mode: "jump"}); err == nil {
+		t.Errorf("want error for unknown mutation mode")
+	}
+	if _, err := NewImageDNAMutation(MutationParams{Limits: limits, Mode: MutationGaussian, PointSigma: -1}); err == nil {
+		t.Errorf("want error for negative standard deviation")
+	}
+}
@@ -0,0 +1,196 @@
+package evolver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aurelien-rainone/evolve/framework"
+)
+
+const (
+	sockName           = "generation.sock"
+	notifyWriteTimeout = time.Second // time given to a client to read a message
+	notifyBacklog      = 16          // messages queued per client, newer ones are dropped
+)
+
+// notifier broadcasts newline-delimited JSON messages to all the clients
+// connected to a unix socket.
+type notifier struct {
+	ln      net.Listener
+	mu      sync.Mutex
+	clients map[*notifyClient]struct{}
+	writers sync.WaitGroup // client writing goroutines
+	done    chan struct{}  // closed when the accept loop returns
+}
+
+// notifyClient is a client connected to the notifier socket. Messages are
+// written by a goroutine per client, so that slow clients don't delay the
+// evolution.
+type notifyClient struct {
+	conn net.Conn
+	msgs chan []byte // messages to write, closed when the client is removed
+}
+
+func newNotifier(sockPath string) (*notifier, error) {
+	// remove the socket file a previous run may have left
+	os.Remove(sockPath)
+	ln, err := net.Listen("unix", sockPath)
+	if err != nil {
+		return nil, fmt.Errorf("can't listen on unix socket: %v", err)
+	}
+	n := &notifier{
+		ln:      ln,
+		clients: make(map[*notifyClient]struct{}),
+		done:    make(chan struct{}),
+	}
+	go n.accept()
+	return n, nil
+}
+
+// accept accepts connections until the listener is closed.
+func (n *notifier) accept() {
+	defer close(n.done)
+	for {
+		conn, err := n.ln.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Println("error accepting socket connection:", err)
+			}
+			return
+		}
+		log.Println("accepted socket connection")
+		c := &notifyClient{conn: conn, msgs: make(chan []byte, notifyBacklog)}
+		n.mu.Lock()
+		n.clients[c] = struct{}{}
+		n.mu.Unlock()
+		n.writers.Add(1)
+		go n.write(c)
+	}
+}
+
+// write writes the messages of c until it's removed, or can't be written to.
+func (n *notifier) write(c *notifyClient) {
+	defer n.writers.Done()
+	defer c.conn.Close()
+	for msg := range c.msgs {
+		c.conn.SetWriteDeadline(time.Now().Add(notifyWriteTimeout))
+		if _, err := c.conn.Write(msg); err != nil {
+			log.Println("closing socket connection:", err)
+			n.mu.Lock()
+			n.remove(c)
+			n.mu.Unlock()
+			return
+		}
+	}
+}
+
+// remove removes c from the clients, its writing goroutine then disconnects
+// it. n.mu must be held.
+func (n *notifier) remove(c *notifyClient) {
+	if _, ok := n.clients[c]; ok {
+		delete(n.clients, c)
+		close(c.msgs)
+	}
+}
+
+// broadcast queues v, encoded as a line of JSON, to all clients. Clients that
+// can't be written to are disconnected, messages are dropped for those which
+// already have notifyBacklog messages queued.
+func (n *notifier) broadcast(v interface{}) error {
+	msg, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	msg = append(msg, '\n')
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for c := range n.clients {
+		select {
+		case c.msgs <- msg:
+		default:
+			// slow client
+		}
+	}
+	return nil
+}
+
+// numClients returns the number of connected clients.
+func (n *notifier) numClients() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return len(n.clients)
+}
+
+// close stops accepting connections, and disconnects all clients once their
+// queued messages are written.
+func (n *notifier) close() error {
+	err := n.ln.Close()
+	<-n.done
+
+	n.mu.Lock()
+	for c := range n.clients {
+		n.remove(c)
+	}
+	n.mu.Unlock()
+	n.writers.Wait()
+	return err
+}
+
+// generationEvent is the message sent to viewers for each notified generation.
+type generationEvent struct {
+	Generation int     `json:"generation"`
+	Fitness    float64 `json:"fitness"`
+	// Snapshot is the name of the image of the best candidate, relative to
+	// the output directory, empty if no image was saved for this generation.
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// notifyObserver notifies the viewers connected to the output directory socket
+// that new generation data is available.
+type notifyObserver struct {
+	freq     int           // notify every N generations
+	best     *bestObserver // observer saving snapshots, may be nil
+	notifier *notifier
+	offset   int // generation number of the resumed checkpoint
+}
+
+func newNotifyObserver(freq int, sockPath string, best *bestObserver, offset int) (*notifyObserver, error) {
+	if freq == 0 {
+		return nil, fmt.Errorf("notifyObserver frequency can't be 0")
+	}
+	n, err := newNotifier(sockPath)
+	if err != nil {
+		return nil, err
+	}
+	return &notifyObserver{freq: freq, best: best, notifier: n, offset: offset}, nil
+}
+
+func (o *notifyObserver) PopulationUpdate(data *framework.PopulationData) {
+	generation := o.offset + data.GenerationNumber()
+	if generation%o.freq != 0 {
+		return
+	}
+	ev := generationEvent{
+		Generation: generation,
+		Fitness:    data.BestCandidateFitness(),
+	}
+	if o.best != nil {
+		ev.Snapshot = o.best.snapshot(generation)
+	}
+	if err := o.notifier.broadcast(ev); err != nil {
+		log.Println("couldn't notify viewers:", err)
+	}
+}
+
+func (o *notifyObserver) close() {
+	if err := o.notifier.close(); err != nil {
+		log.Printf("error closing unix socket listener: %v\n", err)
+	}
+}
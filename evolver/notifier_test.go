@@ -0,0 +1,123 @@
+package evolver
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNotifier(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sockPath := path.Join(dir, sockName)
+	n, err := newNotifier(sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.close()
+
+	var conns []net.Conn
+	for i := 0; i < 3; i++ {
+		conn, err := net.Dial("unix", sockPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns = append(conns, conn)
+	}
+	waitClients := func(want int) {
+		for deadline := time.Now().Add(5 * time.Second); n.numClients() != want; {
+			if time.Now().After(deadline) {
+				t.Fatalf("got %v clients, want %v", n.numClients(), want)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+	waitClients(len(conns))
+
+	want := generationEvent{Generation: 200, Fitness: 1.5, Snapshot: "200.png"}
+	if err := n.broadcast(want); err != nil {
+		t.Fatal(err)
+	}
+	for i, conn := range conns {
+		line, err := bufio.NewReader(conn).ReadBytes('\n')
+		if err != nil {
+			t.Fatalf("client %v: %v", i, err)
+		}
+		var got generationEvent
+		if err := json.Unmarshal(line, &got); err != nil {
+			t.Fatalf("client %v: %v", i, err)
+		}
+		if got != want {
+			t.Errorf("client %v: got %+v, want %+v", i, got, want)
+		}
+	}
+
+	// dead clients are removed on the next broadcasts
+	conns[0].Close()
+	for deadline := time.Now().Add(5 * time.Second); n.numClients() != 2; {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %v clients, want 2", n.numClients())
+		}
+		n.broadcast(want)
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestNotifierSlowClient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notifier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	sockPath := path.Join(dir, sockName)
+	n, err := newNotifier(sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer n.close()
+
+	// a client that never reads
+	conn, err := net.Dial("unix", sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for deadline := time.Now().Add(5 * time.Second); n.numClients() != 1; {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %v clients, want 1", n.numClients())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// broadcasts don't wait for it, even once its socket buffer is full
+	msg := struct{ Data string }{strings.Repeat("x", 64<<10)}
+	start := time.Now()
+	for i := 0; i < 200; i++ {
+		if err := n.broadcast(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if d := time.Since(start); d > notifyWriteTimeout/2 {
+		t.Errorf("broadcasts took %v", d)
+	}
+
+	// it's disconnected once a write times out
+	for deadline := time.Now().Add(5 * time.Second); n.numClients() != 0; {
+		if time.Now().After(deadline) {
+			t.Fatalf("slow client wasn't disconnected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
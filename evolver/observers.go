@@ -0,0 +1,230 @@
+package evolver
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/aurelien-rainone/evolve/framework"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+type sqliteObserver struct {
+	freq     int                 // backup every N generations
+	outDir   string              // output directory
+	recorder *populationRecorder // gives access to the elite, nil to only store the best genome
+	db       *sql.DB             // sqlite db
+	sqlConn  *sql.Conn           // keep connection here, nobody else will use it
+	runID    int64               // id of the run in the runs table
+	offset   int                 // generation number of the resumed checkpoint
+}
+
+func newSqliteObserver(freq int, outDir string, recorder *populationRecorder, meta runMetadata, offset int) (o *sqliteObserver, err error) {
+	if freq == 0 {
+		return nil, fmt.Errorf("sqliteObserver frequency can't be 0")
+	}
+
+	o = &sqliteObserver{freq: freq, outDir: outDir, recorder: recorder, offset: offset}
+
+	if err = o.setupSQL(meta); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// setupSQL opens the evolution database, creating or upgrading it if needed,
+// and records the run described by meta.
+func (o *sqliteObserver) setupSQL(meta runMetadata) error {
+	dbPath := path.Join(o.outDir, dbName)
+
+	var err error
+	o.db, err = sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return fmt.Errorf("can't open sqlite db: %v", err)
+	}
+	o.sqlConn, err = o.db.Conn(context.TODO())
+	if err != nil {
+		return fmt.Errorf("can't open sqlite connection: %v", err)
+	}
+	if err = migrate(context.TODO(), o.sqlConn); err != nil {
+		return err
+	}
+	o.runID, err = insertRun(context.TODO(), o.sqlConn, meta)
+	return err
+}
+
+func (o *sqliteObserver) PopulationUpdate(data *framework.PopulationData) {
+	genNum := o.offset + data.GenerationNumber()
+	if genNum%o.freq == 0 {
+
+		// fill sql table with generation data
+		tx, err := o.sqlConn.BeginTx(context.TODO(), nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		stmt, err := tx.Prepare(insertGenerationStr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer stmt.Close()
+		_, err = stmt.Exec(
+			o.runID,
+			data.BestCandidateFitness(),
+			data.MeanFitness(),
+			data.FitnessStandardDeviation(),
+			data.IsNaturalFitness(),
+			data.PopulationSize(),
+			data.EliteCount(),
+			genNum,
+			data.ElapsedTime()/time.Second,
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		// store the genomes of the best candidates
+		genomes := []*ImageDNA{data.BestCandidate().(*ImageDNA)}
+		if o.recorder != nil {
+			genomes = elite(genomes[0], o.recorder.population(), data.EliteCount())
+		}
+		if err = insertGenomes(tx, o.runID, genNum, genomes); err != nil {
+			log.Fatal(err)
+		}
+		tx.Commit()
+	}
+}
+
+func (o *sqliteObserver) close() {
+	if err := o.sqlConn.Close(); err != nil {
+		log.Printf("error closing database connection: %v\n", err)
+	}
+	if err := o.db.Close(); err != nil {
+		log.Printf("error closing database: %v\n", err)
+	}
+}
+
+type logObserver struct {
+	freq   int // print statistics every N generations
+	offset int // generation number of the resumed checkpoint
+}
+
+func newLogObserver(freq, offset int) (o *logObserver, err error) {
+	if freq == 0 {
+		return nil, fmt.Errorf("logObserver frequency can't be 0")
+	}
+	return &logObserver{freq: freq, offset: offset}, nil
+}
+
+func (o *logObserver) PopulationUpdate(data *framework.PopulationData) {
+	if generation := o.offset + data.GenerationNumber(); generation%o.freq == 0 {
+		log.Printf("Generation %d: best: %.2f mean: %.2f stddev: %.2f\n",
+			generation, data.BestCandidateFitness(), data.MeanFitness(), data.FitnessStandardDeviation())
+	}
+}
+
+type bestObserver struct {
+	freq    int    // save best candidate every N generations
+	outDir  string // output directory
+	pattern string // fmt format of file names, given the generation number
+	format  string // output image format
+	quality int    // jpeg quality
+	offset  int    // generation number of the resumed checkpoint
+}
+
+func newBestObserver(freq int, outDir, pattern, format string, quality, offset int) (o *bestObserver, err error) {
+	if freq == 0 {
+		return nil, fmt.Errorf("bestObserver frequency can't be 0")
+	}
+	// different generations must have different file names
+	if n1, n2 := fmt.Sprintf(pattern, 1), fmt.Sprintf(pattern, 2); n1 == n2 || strings.Contains(n1, "%!") {
+		return nil, fmt.Errorf("invalid snapshot pattern %q, want a format of the generation number, such as %%d", pattern)
+	}
+	return &bestObserver{freq: freq, outDir: outDir, pattern: pattern, format: format, quality: quality, offset: offset}, nil
+}
+
+// snapshot returns the name of the image saved for generation, relative to the
+// output directory, or an empty string if none is saved.
+func (o *bestObserver) snapshot(generation int) string {
+	if generation%o.freq != 0 {
+		return ""
+	}
+	return fmt.Sprintf(o.pattern, generation) + imageExt(o.format)
+}
+
+func (o *bestObserver) PopulationUpdate(data *framework.PopulationData) {
+	generation := o.offset + data.GenerationNumber()
+	if generation%o.freq == 0 {
+		// update best candidate
+		best := data.BestCandidate().(*ImageDNA)
+		base := path.Join(o.outDir, fmt.Sprintf(o.pattern, generation))
+		if err := SaveImage(base, best.Render(), o.format, o.quality); err != nil {
+			log.Println("couldn't save snapshot:", err)
+		}
+		if err := SaveSVG(base+".svg", best); err != nil {
+			log.Println("couldn't save snapshot:", err)
+		}
+	}
+}
+
+type checkpointObserver struct {
+	freq     int                 // checkpoint every N generations
+	outDir   string              // output directory
+	recorder *populationRecorder // gives access to the whole population
+	seed     int64               // seed of the pseudo random number generator
+	offset   int                 // generation number of the resumed checkpoint
+	lastGen  int                 // last observed generation
+}
+
+func newCheckpointObserver(freq int, outDir string, recorder *populationRecorder, seed int64, offset int) (o *checkpointObserver, err error) {
+	if freq == 0 {
+		return nil, fmt.Errorf("checkpointObserver frequency can't be 0")
+	}
+	return &checkpointObserver{
+		freq:     freq,
+		outDir:   outDir,
+		recorder: recorder,
+		seed:     seed,
+		offset:   offset,
+	}, nil
+}
+
+func (o *checkpointObserver) PopulationUpdate(data *framework.PopulationData) {
+	o.lastGen = data.GenerationNumber()
+	if (o.offset+o.lastGen)%o.freq == 0 {
+		if err := o.save(data.BestCandidate().(*ImageDNA)); err != nil {
+			log.Println("couldn't write checkpoint:", err)
+		}
+	}
+}
+
+// save writes a checkpoint of the last evaluated population, of which best is
+// the best candidate.
+func (o *checkpointObserver) save(best *ImageDNA) error {
+	pop := o.recorder.population()
+	if len(pop) == 0 {
+		return nil
+	}
+	ckpt := &Checkpoint{
+		Generation: o.offset + o.lastGen,
+		Seed:       o.seed,
+		Best:       best,
+		Population: make([]*ImageDNA, len(pop)),
+	}
+	for i, c := range pop {
+		ckpt.Population[i] = c.(*ImageDNA)
+	}
+	return WriteCheckpoint(o.outDir, ckpt)
+}
+
+// lastObserver records the data of the last generation.
+type lastObserver struct {
+	data *framework.PopulationData
+}
+
+func (o *lastObserver) PopulationUpdate(data *framework.PopulationData) {
+	o.data = data
+}
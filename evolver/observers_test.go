@@ -0,0 +1,35 @@
+package evolver
+
+import "testing"
+
+func TestBestObserverPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    string // snapshot of generation 200, empty if pattern is invalid
+	}{
+		{"%d", "200.png"},
+		{"gen-%06d", "gen-000200.png"},
+		{"best", ""},
+		{"%s", ""},
+		{"%d-%d", ""},
+	}
+	for _, tt := range tests {
+		o, err := newBestObserver(100, "", tt.pattern, OutputPNG, 0, 0)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("pattern %q: want error", tt.pattern)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("pattern %q: unexpected error: %v", tt.pattern, err)
+			continue
+		}
+		if got := o.snapshot(200); got != tt.want {
+			t.Errorf("pattern %q: got snapshot %q, want %q", tt.pattern, got, tt.want)
+		}
+		if got := o.snapshot(250); got != "" {
+			t.Errorf("pattern %q: got snapshot %q for an unsampled generation", tt.pattern, got)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package evolver
+
+import "fmt"
+
+// Limits bounds the size of ImageDNA genomes.
+type Limits struct {
+	MinPolys, MaxPolys   int // number of polygons in an image
+	MinPoints, MaxPoints int // number of points in a polygon
+}
+
+// Validate checks that the limits are consistent.
+func (l Limits) Validate() error {
+	switch {
+	case l.MinPolys < 1:
+		return fmt.Errorf("minimum number of polygons must be at least 1, got %v", l.MinPolys)
+	case l.MinPolys > l.MaxPolys:
+		return fmt.Errorf("minimum number of polygons (%v) is greater than maximum (%v)", l.MinPolys, l.MaxPolys)
+	case l.MinPoints < 3:
+		return fmt.Errorf("minimum number of points must be at least 3, got %v", l.MinPoints)
+	case l.MinPoints > l.MaxPoints:
+		return fmt.Errorf("minimum number of points (%v) is greater than maximum (%v)", l.MinPoints, l.MaxPoints)
+	}
+	return nil
+}
+
+// MutationParams configures the ImageDNA mutation operator.
+type MutationParams struct {
+	Limits
+
+	// frequency of the shapes of added polygons
+	Shapes ShapeMix
+
+	// image level mutation rates [0, 1]
+	AddPoly, RemovePoly, SwapPolys float64
+
+	// polygon level mutation rates [0, 1]
+	AddPoint, RemovePoint, ChangeColor float64
+
+	// polygon transform rates [0, 1]
+	Translate, Scale, Rotate float64
+
+	// point level mutation rates [0, 1]
+	MovePoint float64
+
+	// Mode of the move-point and change-color mutations: random (the
+	// default) or gaussian
+	Mode string
+
+	// standard deviations of gaussian steps
+	PointSigma     float64 // point move, in pixels
+	ColorSigma     float64 // color channels change, out of 255
+	AlphaSigma     float64 // alpha channel change, out of 255
+	TranslateSigma float64 // polygon translation, in pixels
+	ScaleSigma     float64 // polygon relative scale change
+	RotateSigma    float64 // polygon rotation, in degrees
+}
+
+// CrossoverParams configures the ImageDNA crossover operator.
+type CrossoverParams struct {
+	Limits
+
+	// Operator is the name of the crossover operator: unequal, uniform or
+	// spatial
+	Operator string
+	// Probability [0, 1] that crossover is applied to a pair of parents
+	Probability float64
+	// Points is the number of crossover points
+	Points int
+}
+
+// SelectionParams configures the selection strategy.
+type SelectionParams struct {
+	// Strategy is the name of the selection strategy: identity, tournament,
+	// roulette, rank, truncation or sigma
+	Strategy string
+
+	TournamentSize        int     // number of contestants of each tournament
+	TournamentProbability float64 // probability (0.5, 1] that the fittest contestant wins
+	TruncationRatio       float64 // ratio (0, 1] of the fittest candidates that are selected
+}
+
+// limits returns the genome limits set in the options.
+func (o *Options) limits() Limits {
+	return Limits{
+		MinPolys:  o.Image.MinPolys,
+		MaxPolys:  o.Image.MaxPolys,
+		MinPoints: o.Polygon.MinPoints,
+		MaxPoints: o.Polygon.MaxPoints,
+	}
+}
+
+// shapeMix returns the shapes mix set in the options.
+func (o *Options) shapeMix() ShapeMix {
+	return ShapeMix{
+		ShapePolygon:     o.Shapes.Polygon,
+		ShapeTriangle:    o.Shapes.Triangle,
+		ShapeCircle:      o.Shapes.Circle,
+		ShapeEllipse:     o.Shapes.Ellipse,
+		ShapeRect:        o.Shapes.Rect,
+		ShapeRotatedRect: o.Shapes.RotRect,
+		ShapeBezier:      o.Shapes.Bezier,
+	}
+}
+
+// mutationParams returns the mutation parameters set in the options.
+func (o *Options) mutationParams() MutationParams {
+	return MutationParams{
+		Limits:      o.limits(),
+		Shapes:      o.shapeMix(),
+		AddPoly:     o.Mutation.Image.AddPoly,
+		RemovePoly:  o.Mutation.Image.RemovePoly,
+		SwapPolys:   o.Mutation.Image.SwapPolys,
+		AddPoint:    o.Mutation.Polygon.AddPoint,
+		RemovePoint: o.Mutation.Polygon.RemovePoint,
+		ChangeColor: o.Mutation.Polygon.ChangeColor,
+		Translate:   o.Mutation.Polygon.Translate,
+		Scale:       o.Mutation.Polygon.Scale,
+		Rotate:      o.Mutation.Polygon.Rotate,
+		MovePoint:   o.Mutation.Point.Move,
+
+		Mode:           o.Mutation.Mode,
+		PointSigma:     o.Mutation.Sigma.Point,
+		ColorSigma:     o.Mutation.Sigma.Color,
+		AlphaSigma:     o.Mutation.Sigma.Alpha,
+		TranslateSigma: o.Mutation.Sigma.Translate,
+		ScaleSigma:     o.Mutation.Sigma.Scale,
+		RotateSigma:    o.Mutation.Sigma.Rotate,
+	}
+}
+
+// crossoverParams returns the crossover parameters set in the options.
+func (o *Options) crossoverParams() CrossoverParams {
+	return CrossoverParams{
+		Limits:      o.limits(),
+		Operator:    o.Crossover.Operator,
+		Probability: o.Crossover.Probability,
+		Points:      o.Crossover.Points,
+	}
+}
+
+// selectionParams returns the selection parameters set in the options.
+func (o *Options) selectionParams() SelectionParams {
+	return SelectionParams{
+		Strategy:              o.Selection.Strategy,
+		TournamentSize:        o.Selection.Tournament.Size,
+		TournamentProbability: o.Selection.Tournament.Probability,
+		TruncationRatio:       o.Selection.Truncation.Ratio,
+	}
+}
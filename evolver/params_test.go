@@ -0,0 +1,55 @@
+package evolver
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestLimitsValidate(t *testing.T) {
+	tests := []struct {
+		limits  Limits
+		wantErr bool
+	}{
+		{Limits{MinPolys: 1, MaxPolys: 1, MinPoints: 3, MaxPoints: 3}, false},
+		{Limits{MinPolys: 10, MaxPolys: 20, MinPoints: 3, MaxPoints: 8}, false},
+		{Limits{MinPolys: 0, MaxPolys: 20, MinPoints: 3, MaxPoints: 8}, true},
+		{Limits{MinPolys: 30, MaxPolys: 20, MinPoints: 3, MaxPoints: 8}, true},
+		{Limits{MinPolys: 10, MaxPolys: 20, MinPoints: 2, MaxPoints: 8}, true},
+		{Limits{MinPolys: 10, MaxPolys: 20, MinPoints: 9, MaxPoints: 8}, true},
+	}
+	for _, tt := range tests {
+		if err := tt.limits.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v.Validate() error = %v, wantErr %v", tt.limits, err, tt.wantErr)
+		}
+	}
+}
+
+func TestOperatorsRejectInvalidLimits(t *testing.T) {
+	invalid := Limits{MinPolys: 30, MaxPolys: 20, MinPoints: 3, MaxPoints: 8}
+	if _, err := NewImageDNAFactory(10, 10, invalid, ShapeMix{}); err == nil {
+		t.Errorf("NewImageDNAFactory: want error for invalid limits")
+	}
+	if _, err := NewImageDNAMutation(MutationParams{Limits: invalid}); err == nil {
+		t.Errorf("NewImageDNAMutation: want error for invalid limits")
+	}
+	if _, err := NewImageDNACrossover(CrossoverParams{Limits: invalid, Probability: 1, Points: 1}); err == nil {
+		t.Errorf("NewImageDNACrossover: want error for invalid limits")
+	}
+}
+
+func TestGeneratorLimits(t *testing.T) {
+	rng := rand.New(rand.NewSource(99))
+	limits := Limits{MinPolys: 5, MaxPolys: 10, MinPoints: 3, MaxPoints: 6}
+	gen := &imageDNAGenerator{imgW: 50, imgH: 40, limits: limits}
+	for i := 0; i < 100; i++ {
+		img := gen.GenerateRandomCandidate(rng).(*ImageDNA)
+		if len(img.Polys) < limits.MinPolys || len(img.Polys) > limits.MaxPolys {
+			t.Fatalf("got %v polygons, want [%v, %v]", len(img.Polys), limits.MinPolys, limits.MaxPolys)
+		}
+		for _, p := range img.Polys {
+			if len(p.Pts) < limits.MinPoints || len(p.Pts) > limits.MaxPoints {
+				t.Fatalf("got %v points, want [%v, %v]", len(p.Pts), limits.MinPoints, limits.MaxPoints)
+			}
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package evolver
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/aurelien-rainone/evolve/framework"
+	"github.com/aurelien-rainone/evolve/termination"
+	xdraw "golang.org/x/image/draw"
+)
+
+// PyramidStage configures a stage of the pyramid evolution, evolving the
+// population against a downscaled reference image.
+type PyramidStage struct {
+	// Scale (0, 1) of the reference image of the stage
+	Scale float64
+	// Generations is the maximum number of generations of the stage, 0 for
+	// no limit
+	Generations int
+	// Stagnation ends the stage once the best fitness hasn't improved for
+	// this number of generations, 0 to never end on stagnation
+	Stagnation int
+}
+
+// validatePyramid checks that stages have increasing scales in (0, 1), and
+// that each of them ends.
+func validatePyramid(stages []PyramidStage) error {
+	for i, s := range stages {
+		if s.Scale <= 0 || s.Scale >= 1 {
+			return fmt.Errorf("pyramid stage %v: invalid scale %v, want (0, 1)", i, s.Scale)
+		}
+		if i > 0 && s.Scale <= stages[i-1].Scale {
+			return fmt.Errorf("pyramid stage %v: scale %v isn't greater than the previous stage scale", i, s.Scale)
+		}
+		if s.Generations < 0 || s.Stagnation < 0 {
+			return fmt.Errorf("pyramid stage %v: generations and stagnation must be positive", i)
+		}
+		if s.Generations == 0 && s.Stagnation == 0 {
+			return fmt.Errorf("pyramid stage %v: needs a number of generations or a stagnation limit", i)
+		}
+	}
+	return nil
+}
+
+// stageSize returns the dimensions of the reference image of a stage.
+func stageSize(w, h int, scale float64) (int, int) {
+	return max(1, int(math.Round(float64(w)*scale))), max(1, int(math.Round(float64(h)*scale)))
+}
+
+// evolvePyramid evolves a population against the downscaled references of
+// the pyramid stages, each stage starting from the population of the previous
+// one, rescaled. It returns the population of the last stage, rescaled to the
+// size of ref, the best candidate first.
+//
+// Pyramid stages are only observed by the log observer, if enabled. They end
+// early once ctx is done or the time limit of the run, started at start, is
+// reached.
+func evolvePyramid(ctx context.Context, start time.Time, ref *image.RGBA, mask *image.Gray, opts *Options,
+	pipeline framework.EvolutionaryOperator, selection framework.SelectionStrategy, rng *rand.Rand) ([]framework.Candidate, error) {
+	var (
+		seeds []framework.Candidate
+		w, h  = ref.Bounds().Dx(), ref.Bounds().Dy()
+	)
+	for i, stage := range opts.Pyramid.Stages {
+		sw, sh := stageSize(w, h, stage.Scale)
+		log.Printf("pyramid stage %v: %v x %v", i, sw, sh)
+
+		var smask *image.Gray
+		if mask != nil {
+			smask = image.NewGray(image.Rect(0, 0, sw, sh))
+			xdraw.CatmullRom.Scale(smask, smask.Bounds(), mask, mask.Bounds(), draw.Src, nil)
+		}
+		sref := image.NewRGBA(image.Rect(0, 0, sw, sh))
+		xdraw.CatmullRom.Scale(sref, sref.Bounds(), ref, ref.Bounds(), draw.Src, nil)
+
+		engine, evaluator, err := newEngine(sref, smask, opts, pipeline, selection, rng)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Observe.Log.Enabled {
+			logObs, err := newLogObserver(opts.Observe.Log.Frequency, 0)
+			if err != nil {
+				return nil, err
+			}
+			engine.AddEvolutionObserver(logObs)
+		}
+		last := &lastObserver{}
+		engine.AddEvolutionObserver(last)
+
+		conds, err := opts.runLimits(ctx, start)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, termination.NewTargetFitness(0, false))
+		if stage.Generations > 0 {
+			conds = append(conds, generationCount{max: stage.Generations})
+		}
+		if stage.Stagnation > 0 {
+			conds = append(conds, &stagnation{limit: stage.Stagnation})
+		}
+		var best framework.Candidate
+		if seeds != nil {
+			best = engine.EvolveWithSeedCandidates(opts.Population.NumIndividuals, opts.Population.EliteCount, seeds, conds...)
+		} else {
+			best = engine.Evolve(opts.Population.NumIndividuals, opts.Population.EliteCount, conds...)
+		}
+		if last.data != nil {
+			log.Printf("pyramid stage %v: %v generations, best fitness %v",
+				i, last.data.GenerationNumber()+1, last.data.BestCandidateFitness())
+		}
+
+		// seed the next stage with the rescaled population, best first
+		nw, nh := w, h
+		if i+1 < len(opts.Pyramid.Stages) && ctx.Err() == nil {
+			nw, nh = stageSize(w, h, opts.Pyramid.Stages[i+1].Scale)
+		}
+		seeds = []framework.Candidate{best.(*ImageDNA).rescale(nw, nh)}
+		for _, c := range evaluator.population() {
+			if c != best && len(seeds) < opts.Population.NumIndividuals {
+				seeds = append(seeds, c.(*ImageDNA).rescale(nw, nh))
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return seeds, nil
+}
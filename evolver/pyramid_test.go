@@ -0,0 +1,84 @@
+package evolver
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/aurelien-rainone/evolve/framework"
+)
+
+func TestValidatePyramid(t *testing.T) {
+	tests := []struct {
+		stages  []PyramidStage
+		wantErr bool
+	}{
+		{nil, false},
+		{[]PyramidStage{{Scale: 0.25, Generations: 100}, {Scale: 0.5, Stagnation: 50}}, false},
+		{[]PyramidStage{{Scale: 1, Generations: 100}}, true},
+		{[]PyramidStage{{Scale: 0, Generations: 100}}, true},
+		{[]PyramidStage{{Scale: 0.5, Generations: 100}, {Scale: 0.25, Generations: 100}}, true},
+		{[]PyramidStage{{Scale: 0.5}}, true},
+		{[]PyramidStage{{Scale: 0.5, Generations: -1, Stagnation: 10}}, true},
+	}
+	for _, tt := range tests {
+		if err := validatePyramid(tt.stages); (err != nil) != tt.wantErr {
+			t.Errorf("validatePyramid(%+v) error = %v, wantErr %v", tt.stages, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRescale(t *testing.T) {
+	img := &ImageDNA{
+		W: 40, H: 20,
+		Polys: []Poly{
+			{Col: color.NRGBA{R: 255, A: 51}, Pts: []image.Point{{0, 0}, {10, 5}, {39, 19}}},
+			{Shape: ShapeCircle, Col: color.NRGBA{G: 255, A: 20}, Pts: []image.Point{{20, 10}, {25, 10}}},
+		},
+	}
+	up := img.rescale(160, 40)
+	if up.W != 160 || up.H != 40 {
+		t.Fatalf("got dimensions %v x %v, want 160 x 40", up.W, up.H)
+	}
+	want := [][]image.Point{{{0, 0}, {40, 10}, {156, 38}}, {{80, 20}, {100, 20}}}
+	for i, p := range up.Polys {
+		if p.Shape != img.Polys[i].Shape || p.Col != img.Polys[i].Col {
+			t.Errorf("polygon %v: got shape %v color %v, want %v %v", i, p.Shape, p.Col, img.Polys[i].Shape, img.Polys[i].Col)
+		}
+		for j, pt := range p.Pts {
+			if pt != want[i][j] {
+				t.Errorf("polygon %v point %v: got %v, want %v", i, j, pt, want[i][j])
+			}
+		}
+	}
+	// the original is left untouched
+	if img.Polys[0].Pts[1] != image.Pt(10, 5) {
+		t.Errorf("rescale modified the original genome")
+	}
+}
+
+func TestMutateTinyStage(t *testing.T) {
+	limits := Limits{MinPolys: 1, MaxPolys: 5, MinPoints: 3, MaxPoints: 6}
+	rng := rand.New(rand.NewSource(3))
+	for _, mode := range []string{MutationRandom, MutationGaussian} {
+		op, err := NewImageDNAMutation(MutationParams{
+			Limits: limits, Mode: mode,
+			AddPoly: 1, RemovePoly: 1, SwapPolys: 1,
+			AddPoint: 1, RemovePoint: 1, ChangeColor: 1,
+			Translate: 1, Scale: 1, Rotate: 1, MovePoint: 1,
+			PointSigma: 5, ColorSigma: 5, AlphaSigma: 5, TranslateSigma: 5, ScaleSigma: 0.1, RotateSigma: 10,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// stages smaller than the margins of random points
+		for _, size := range []int{1, 2, 20} {
+			gen := &imageDNAGenerator{imgW: size, imgH: size, limits: limits}
+			pop := []framework.Candidate{gen.GenerateRandomCandidate(rng), gen.GenerateRandomCandidate(rng)}
+			for i := 0; i < 50; i++ {
+				pop = op.Apply(pop, rng)
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package evolver
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/aurelien-rainone/evolve/framework"
+	"github.com/aurelien-rainone/evolve/number"
+	"github.com/aurelien-rainone/evolve/selection"
+)
+
+// names of the available selection strategies, as they appear in the
+// configuration
+const (
+	SelectionIdentity   = "identity"
+	SelectionTournament = "tournament"
+	SelectionRoulette   = "roulette"
+	SelectionRank       = "rank"
+	SelectionTruncation = "truncation"
+	SelectionSigma      = "sigma"
+)
+
+// NewSelectionStrategy creates the selection strategy configured by params.
+func NewSelectionStrategy(params SelectionParams) (framework.SelectionStrategy, error) {
+	switch params.Strategy {
+	case SelectionIdentity, "":
+		return selection.Identity{}, nil
+
+	case SelectionTournament:
+		prob, err := number.NewProbability(params.TournamentProbability)
+		if err != nil {
+			return nil, fmt.Errorf("tournament selection probability error: %v", err)
+		}
+		return newTournamentSelection(params.TournamentSize, prob)
+
+	case SelectionRoulette:
+		return selection.RouletteWheelSelection{}, nil
+
+	case SelectionRank:
+		return selection.NewRankSelection(), nil
+
+	case SelectionTruncation:
+		if params.TruncationRatio <= 0 || params.TruncationRatio > 1 {
+			return nil, fmt.Errorf("truncation selection ratio must be in (0, 1], got %v", params.TruncationRatio)
+		}
+		return selection.NewTruncationSelection(selection.WithConstantSelectionRatio(params.TruncationRatio))
+
+	case SelectionSigma:
+		return selection.NewSigmaScaling(), nil
+	}
+	return nil, fmt.Errorf("unknown selection strategy %q", params.Strategy)
+}
+
+// tournamentSelection is a selection strategy that runs, for each selected
+// candidate, a tournament between a number of randomly chosen contestants.
+// The fittest contestant wins the tournament with a given probability p,
+// if it doesn't, the second fittest wins with probability p, and so on.
+//
+// selection.TournamentSelection isn't used as its tournaments always have 2
+// contestants, while the size of these ones is configurable.
+type tournamentSelection struct {
+	size int                // number of contestants of each tournament
+	prob number.Probability // probability that the fittest contestant wins
+}
+
+func newTournamentSelection(size int, prob number.Probability) (*tournamentSelection, error) {
+	if size < 2 {
+		return nil, fmt.Errorf("tournament size must be at least 2, got %v", size)
+	}
+	if prob <= 0.5 {
+		return nil, fmt.Errorf("tournament selection probability must be greater than 0.5, got %v", prob)
+	}
+	return &tournamentSelection{size: size, prob: prob}, nil
+}
+
+func (ts *tournamentSelection) Select(
+	population framework.EvaluatedPopulation,
+	naturalFitnessScores bool,
+	selectionSize int,
+	rng *rand.Rand) []framework.Candidate {
+
+	selected := make([]framework.Candidate, selectionSize)
+	contestants := make([]int, ts.size)
+	for i := 0; i < selectionSize; i++ {
+		for j := range contestants {
+			contestants[j] = rng.Intn(len(population))
+		}
+		// sort contestants, fittest first
+		sort.Slice(contestants, func(a, b int) bool {
+			fa, fb := population[contestants[a]].Fitness(), population[contestants[b]].Fitness()
+			if naturalFitnessScores {
+				return fa > fb
+			}
+			return fa < fb
+		})
+
+		winner := contestants[len(contestants)-1]
+		for _, idx := range contestants[:len(contestants)-1] {
+			if ts.prob.NextEvent(rng) {
+				winner = idx
+				break
+			}
+		}
+		selected[i] = population[winner].Candidate()
+	}
+	return selected
+}
+
+func (ts *tournamentSelection) String() string {
+	return fmt.Sprintf("Tournament Selection (size %v, p = %v)", ts.size, ts.prob)
+}
@@ -0,0 +1,93 @@
+package evolver
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/aurelien-rainone/evolve/framework"
+	"github.com/aurelien-rainone/evolve/number"
+)
+
+// evaluatedPopulation returns a population of candidates which are their own
+// fitness.
+func evaluatedPopulation(t *testing.T, fitness ...float64) framework.EvaluatedPopulation {
+	var pop framework.EvaluatedPopulation
+	for _, f := range fitness {
+		ec, err := framework.NewEvaluatedCandidate(f, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pop = append(pop, ec)
+	}
+	return pop
+}
+
+func TestTournamentSelection(t *testing.T) {
+	pop := evaluatedPopulation(t, 5, 1, 4, 2, 3)
+	tests := []struct {
+		name    string
+		size    int
+		prob    number.Probability
+		natural bool
+		want    float64 // candidate always selected
+	}{
+		{"natural fittest", 50, 1, true, 5},
+		{"non-natural fittest", 50, 1, false, 1},
+		// the fittest never wins, the weakest contestant is the fallback
+		{"natural weakest", 50, 0, true, 1},
+		{"non-natural weakest", 50, 0, false, 5},
+	}
+	rng := rand.New(rand.NewSource(1))
+	for _, tt := range tests {
+		// bypass the probability validation of the constructor
+		ts := &tournamentSelection{size: tt.size, prob: tt.prob}
+		for _, c := range ts.Select(pop, tt.natural, 20, rng) {
+			if c.(float64) != tt.want {
+				t.Errorf("%v: selected %v, want %v", tt.name, c, tt.want)
+				break
+			}
+		}
+	}
+
+	// with p = 0.7, a 2-contestant tournament is mostly won by the fittest
+	ts, err := newTournamentSelection(2, 0.7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wins int
+	const n = 10000
+	sel := ts.Select(evaluatedPopulation(t, 1, 2), true, n, rng)
+	for _, c := range sel {
+		if c.(float64) == 2 {
+			wins++
+		}
+	}
+	// the fittest wins when both contestants are itself (1/4), or with
+	// probability 0.7 when they differ (1/2)
+	if p := float64(wins) / n; p < 0.57 || p > 0.63 {
+		t.Errorf("fittest selected with frequency %v, want 0.6", p)
+	}
+}
+
+func TestTournamentSelectionValidation(t *testing.T) {
+	tests := []struct {
+		size    int
+		prob    number.Probability
+		wantErr bool
+	}{
+		{2, 0.7, false},
+		{5, 1, false},
+		{1, 0.7, true},
+		{0, 0.7, true},
+		{2, 0.5, true},
+		{2, 0.2, true},
+	}
+	for _, tt := range tests {
+		if _, err := newTournamentSelection(tt.size, tt.prob); (err != nil) != tt.wantErr {
+			t.Errorf("newTournamentSelection(%v, %v) error = %v, wantErr %v", tt.size, tt.prob, err, tt.wantErr)
+		}
+	}
+	if _, err := NewSelectionStrategy(SelectionParams{Strategy: SelectionTournament, TournamentSize: 2, TournamentProbability: 1.5}); err == nil {
+		t.Errorf("want error for a probability greater than 1")
+	}
+}
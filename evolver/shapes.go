@@ -0,0 +1,319 @@
+package evolver
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"math/rand"
+
+	"github.com/fogleman/gg"
+)
+
+// Shape is the kind of shape drawn by a Poly. The meaning of the points of a
+// Poly depends on its shape.
+type Shape uint8
+
+// supported shapes
+const (
+	// ShapePolygon is a polygon of vertices Pts.
+	ShapePolygon Shape = iota
+	// ShapeTriangle is a triangle of vertices Pts[0], Pts[1] and Pts[2].
+	ShapeTriangle
+	// ShapeCircle is a circle centered on Pts[0], passing through Pts[1].
+	ShapeCircle
+	// ShapeEllipse is an ellipse centered on Pts[0]. Pts[1] is the end of
+	// its first semi-axis, the second semi-axis is perpendicular to the first
+	// one and as long as Pts[2] is far from the center.
+	ShapeEllipse
+	// ShapeRect is an axis-aligned rectangle of opposite corners Pts[0] and
+	// Pts[1].
+	ShapeRect
+	// ShapeRotatedRect is a rectangle centered on Pts[0], of half-extents
+	// defined as the semi-axes of ShapeEllipse.
+	ShapeRotatedRect
+	// ShapeBezier is a closed smooth curve passing through Pts, made of cubic
+	// Bézier segments.
+	ShapeBezier
+
+	numShapes
+)
+
+var shapeNames = [numShapes]string{"polygon", "triangle", "circle", "ellipse", "rect", "rotrect", "bezier"}
+
+func (s Shape) String() string {
+	if s >= numShapes {
+		return fmt.Sprintf("Shape(%d)", s)
+	}
+	return shapeNames[s]
+}
+
+// ParseShape returns the shape of the given name.
+func ParseShape(name string) (Shape, error) {
+	for s, n := range shapeNames {
+		if n == name {
+			return Shape(s), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown shape %q", name)
+}
+
+// numPoints returns the number of points of shapes of kind s, or 0 if it's
+// variable.
+func (s Shape) numPoints() int {
+	switch s {
+	case ShapeTriangle, ShapeEllipse, ShapeRotatedRect:
+		return 3
+	case ShapeCircle, ShapeRect:
+		return 2
+	}
+	return 0
+}
+
+// centered reports whether shapes of kind s are defined by a center and
+// semi-axes.
+func (s Shape) centered() bool {
+	return s == ShapeCircle || s == ShapeEllipse || s == ShapeRotatedRect
+}
+
+// ShapeMix gives the relative frequency of each shape among randomly created
+// shapes. A mix of all zeros only creates polygons.
+type ShapeMix [numShapes]float64
+
+// Validate checks that the frequencies are positive.
+func (m ShapeMix) Validate() error {
+	for s, f := range m {
+		if f < 0 || math.IsNaN(f) {
+			return fmt.Errorf("invalid %v frequency %v", Shape(s), f)
+		}
+	}
+	return nil
+}
+
+// pick returns a random shape, drawn according to the mix.
+func (m ShapeMix) pick(rng *rand.Rand) Shape {
+	var (
+		sum  float64
+		only Shape // the only shape of the mix, if there's only one
+		n    int   // number of shapes of the mix
+	)
+	for s, f := range m {
+		if f > 0 {
+			sum += f
+			only = Shape(s)
+			n++
+		}
+	}
+	if n <= 1 {
+		// don't consume random numbers when there's no choice
+		return only
+	}
+	x := rng.Float64() * sum
+	for s, f := range m {
+		if x < f {
+			return Shape(s)
+		}
+		x -= f
+	}
+	return only
+}
+
+// randomShape creates and returns a random shape of the given kind, minPts and
+// maxPts bounding the number of points of polygons and Bézier shapes.
+func randomShape(img *ImageDNA, shape Shape, minPts, maxPts int, rng *rand.Rand) Poly {
+	var p Poly
+	switch {
+	case shape.centered():
+		ctr := randomPoint(img, 0, rng)
+		p.Pts = []image.Point{ctr}
+		for i := 1; i < shape.numPoints(); i++ {
+			p.Pts = append(p.Pts, ctr.Add(randomAxis(img, rng)))
+		}
+		p.Col = randomColor(rng)
+	case shape.numPoints() > 0:
+		p = randomPoly(img, shape.numPoints(), shape.numPoints(), rng)
+	default:
+		p = randomPoly(img, minPts, maxPts, rng)
+	}
+	p.Shape = shape
+	return p
+}
+
+// randomAxis returns a random semi-axis, of random orientation and of length
+// up to 30% of the image size.
+func randomAxis(img *ImageDNA, rng *rand.Rand) image.Point {
+	maxLen := math.Max(1, 0.3*float64(min(img.W, img.H)))
+	l := 1 + rng.Float64()*(maxLen-1)
+	a := rng.Float64() * 2 * math.Pi
+	return image.Pt(int(math.Round(l*math.Cos(a))), int(math.Round(l*math.Sin(a))))
+}
+
+// movePoint moves the point j of p at random. Moving the center of a centered
+// shape translates the whole shape, moving another of its points changes its
+// size and orientation.
+func (p *Poly) movePoint(img *ImageDNA, j int, rng *rand.Rand) {
+	switch {
+	case !p.Shape.centered():
+		// TODO: compute margin
+		p.Pts[j] = randomPoint(img, 10, rng)
+	case j == 0:
+		d := randomPoint(img, 0, rng).Sub(p.Pts[0])
+		for k := range p.Pts {
+			p.Pts[k] = p.Pts[k].Add(d)
+		}
+	default:
+		p.Pts[j] = p.Pts[0].Add(randomAxis(img, rng))
+	}
+}
+
+// jitterPoint moves the point j of p by a gaussian step of standard deviation
+// sigma. Like with movePoint, jittering the center of a centered shape
+// translates it.
+func (p *Poly) jitterPoint(img *ImageDNA, j int, sigma float64, rng *rand.Rand) {
+	d := image.Pt(int(math.Round(sigma*rng.NormFloat64())), int(math.Round(sigma*rng.NormFloat64())))
+	if j != 0 || !p.Shape.centered() {
+		// keep points in the image
+		p.Pts[j] = p.Pts[j].Add(d)
+		p.Pts[j].X = max(0, min(img.W, p.Pts[j].X))
+		p.Pts[j].Y = max(0, min(img.H, p.Pts[j].Y))
+		return
+	}
+	// keep the center in the image
+	d.X = max(-p.Pts[0].X, min(img.W-p.Pts[0].X, d.X))
+	d.Y = max(-p.Pts[0].Y, min(img.H-p.Pts[0].Y, d.Y))
+	for k := range p.Pts {
+		p.Pts[k] = p.Pts[k].Add(d)
+	}
+}
+
+// pivot returns the point around which p is scaled and rotated: the center of
+// centered shapes, the center of the bounding box of others.
+func (p *Poly) pivot() gg.Point {
+	if p.Shape.centered() {
+		return fpt(p.Pts[0])
+	}
+	x0, y0, x1, y1 := p.extent()
+	return gg.Point{X: (x0 + x1) / 2, Y: (y0 + y1) / 2}
+}
+
+// transform applies the affine transform m to the points of p.
+func (p *Poly) transform(m gg.Matrix) {
+	for k, pt := range p.Pts {
+		x, y := m.TransformPoint(float64(pt.X), float64(pt.Y))
+		p.Pts[k] = image.Pt(int(math.Round(x)), int(math.Round(y)))
+	}
+}
+
+func fpt(pt image.Point) gg.Point {
+	return gg.Point{X: float64(pt.X), Y: float64(pt.Y)}
+}
+
+// axes returns the center and the semi-axes of a centered shape.
+func (p *Poly) axes() (c, u, v gg.Point) {
+	c = fpt(p.Pts[0])
+	u = fpt(p.Pts[1].Sub(p.Pts[0]))
+	l := u.Distance(gg.Point{})
+	if p.Shape != ShapeCircle {
+		l = fpt(p.Pts[2]).Distance(c)
+	}
+	if n := u.Distance(gg.Point{}); n > 0 {
+		v = gg.Point{X: -u.Y * l / n, Y: u.X * l / n}
+	} else {
+		v = gg.Point{Y: l}
+	}
+	return c, u, v
+}
+
+// corners returns the corners of a rotated rectangle.
+func (p *Poly) corners() []gg.Point {
+	c, u, v := p.axes()
+	return []gg.Point{
+		{X: c.X + u.X + v.X, Y: c.Y + u.Y + v.Y},
+		{X: c.X + u.X - v.X, Y: c.Y + u.Y - v.Y},
+		{X: c.X - u.X - v.X, Y: c.Y - u.Y - v.Y},
+		{X: c.X - u.X + v.X, Y: c.Y - u.Y + v.Y},
+	}
+}
+
+// bezierControls returns the control points of the Bézier segment going from
+// point i to point i+1 of a Bézier shape, so that the curve is smooth at each
+// point (Catmull-Rom spline).
+func (p *Poly) bezierControls(i int) (c1, c2 gg.Point) {
+	n := len(p.Pts)
+	p0, p1 := fpt(p.Pts[(i+n-1)%n]), fpt(p.Pts[i])
+	p2, p3 := fpt(p.Pts[(i+1)%n]), fpt(p.Pts[(i+2)%n])
+	c1 = gg.Point{X: p1.X + (p2.X-p0.X)/6, Y: p1.Y + (p2.Y-p0.Y)/6}
+	c2 = gg.Point{X: p2.X - (p3.X-p1.X)/6, Y: p2.Y - (p3.Y-p1.Y)/6}
+	return c1, c2
+}
+
+// path adds the outline of p to the current path of dc, in genome coordinates.
+func (p *Poly) path(dc *gg.Context) {
+	switch p.Shape {
+	case ShapeCircle:
+		c, u, _ := p.axes()
+		dc.DrawCircle(c.X, c.Y, u.Distance(gg.Point{}))
+	case ShapeEllipse:
+		c, u, v := p.axes()
+		dc.Push()
+		dc.Translate(c.X, c.Y)
+		dc.Rotate(math.Atan2(u.Y, u.X))
+		dc.DrawEllipse(0, 0, u.Distance(gg.Point{}), v.Distance(gg.Point{}))
+		dc.Pop()
+	case ShapeRect:
+		r := image.Rectangle{Min: p.Pts[0], Max: p.Pts[1]}.Canon()
+		dc.DrawRectangle(float64(r.Min.X), float64(r.Min.Y), float64(r.Dx()), float64(r.Dy()))
+	case ShapeRotatedRect:
+		corners := p.corners()
+		dc.MoveTo(corners[0].X, corners[0].Y)
+		for _, pt := range corners[1:] {
+			dc.LineTo(pt.X, pt.Y)
+		}
+	case ShapeBezier:
+		dc.MoveTo(float64(p.Pts[0].X), float64(p.Pts[0].Y))
+		for i := range p.Pts {
+			c1, c2 := p.bezierControls(i)
+			end := fpt(p.Pts[(i+1)%len(p.Pts)])
+			dc.CubicTo(c1.X, c1.Y, c2.X, c2.Y, end.X, end.Y)
+		}
+	default:
+		dc.MoveTo(float64(p.Pts[0].X), float64(p.Pts[0].Y))
+		for _, pt := range p.Pts[1:] {
+			dc.LineTo(float64(pt.X), float64(pt.Y))
+		}
+	}
+}
+
+// extent returns the bounding box of p, in genome coordinates.
+func (p *Poly) extent() (x0, y0, x1, y1 float64) {
+	x0, y0 = math.Inf(1), math.Inf(1)
+	x1, y1 = math.Inf(-1), math.Inf(-1)
+	add := func(pt gg.Point) {
+		x0, y0 = math.Min(x0, pt.X), math.Min(y0, pt.Y)
+		x1, y1 = math.Max(x1, pt.X), math.Max(y1, pt.Y)
+	}
+
+	switch p.Shape {
+	case ShapeCircle, ShapeEllipse:
+		c, u, v := p.axes()
+		ex, ey := math.Hypot(u.X, v.X), math.Hypot(u.Y, v.Y)
+		add(gg.Point{X: c.X - ex, Y: c.Y - ey})
+		add(gg.Point{X: c.X + ex, Y: c.Y + ey})
+	case ShapeRotatedRect:
+		for _, pt := range p.corners() {
+			add(pt)
+		}
+	case ShapeBezier:
+		// the curve lies in the convex hull of its control points
+		for i, pt := range p.Pts {
+			c1, c2 := p.bezierControls(i)
+			add(fpt(pt))
+			add(c1)
+			add(c2)
+		}
+	default:
+		for _, pt := range p.Pts {
+			add(fpt(pt))
+		}
+	}
+	return x0, y0, x1, y1
+}
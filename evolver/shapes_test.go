@@ -0,0 +1,133 @@
+package evolver
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestShapeBounds(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	img := &ImageDNA{W: 80, H: 60}
+	for shape := Shape(0); shape < numShapes; shape++ {
+		for i := 0; i < 20; i++ {
+			p := randomShape(img, shape, 3, 6, rng)
+			if p.Shape != shape {
+				t.Fatalf("got shape %v, want %v", p.Shape, shape)
+			}
+			if n := shape.numPoints(); n != 0 && len(p.Pts) != n {
+				t.Fatalf("%v: got %v points, want %v", shape, len(p.Pts), n)
+			}
+
+			// every pixel the shape covers must be in its bounds, for
+			// incremental rendering to be exact
+			p.Col = color.NRGBA{R: 255, A: 255}
+			dna := &ImageDNA{W: img.W, H: img.H, Polys: []Poly{p}}
+			rendered := dna.Render()
+			bounds := p.bounds()
+			for y := 0; y < img.H; y++ {
+				for x := 0; x < img.W; x++ {
+					if rendered.RGBAAt(x, y).A != 0 && !image.Pt(x, y).In(bounds) {
+						t.Fatalf("%v %v: pixel (%v, %v) is out of bounds %v", shape, p.Pts, x, y, bounds)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestShapeRendering(t *testing.T) {
+	red := color.NRGBA{R: 255, A: 255}
+	tests := []struct {
+		p       Poly
+		in, out image.Point // pixels inside and outside the shape
+	}{
+		{Poly{Shape: ShapeCircle, Pts: []image.Point{{20, 20}, {30, 20}}}, image.Pt(20, 28), image.Pt(28, 28)},
+		{Poly{Shape: ShapeEllipse, Pts: []image.Point{{20, 20}, {35, 0}, {20, 25}}}, image.Pt(30, 10), image.Pt(30, 30)},
+		{Poly{Shape: ShapeRect, Pts: []image.Point{{30, 30}, {10, 20}}}, image.Pt(12, 28), image.Pt(8, 25)},
+		{Poly{Shape: ShapeRotatedRect, Pts: []image.Point{{20, 20}, {30, 30}, {22, 22}}}, image.Pt(28, 27), image.Pt(10, 30)},
+		{Poly{Shape: ShapeTriangle, Pts: []image.Point{{0, 0}, {39, 0}, {0, 39}}}, image.Pt(10, 10), image.Pt(30, 30)},
+		{Poly{Shape: ShapeBezier, Pts: []image.Point{{10, 10}, {30, 10}, {30, 30}, {10, 30}}}, image.Pt(20, 20), image.Pt(2, 2)},
+	}
+	for _, tt := range tests {
+		tt.p.Col = red
+		dna := &ImageDNA{W: 40, H: 40, Polys: []Poly{tt.p}}
+		rendered := dna.Render()
+		if a := rendered.RGBAAt(tt.in.X, tt.in.Y).A; a != 255 {
+			t.Errorf("%v: pixel %v has alpha %v, want 255", tt.p.Shape, tt.in, a)
+		}
+		if a := rendered.RGBAAt(tt.out.X, tt.out.Y).A; a != 0 {
+			t.Errorf("%v: pixel %v has alpha %v, want 0", tt.p.Shape, tt.out, a)
+		}
+	}
+}
+
+func TestShapeMix(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	ref := rand.New(rand.NewSource(1))
+
+	// with a single shape, no random number is consumed
+	if s := (ShapeMix{}).pick(rng); s != ShapePolygon {
+		t.Errorf("got %v, want polygon", s)
+	}
+	if s := (ShapeMix{ShapeCircle: 2}).pick(rng); s != ShapeCircle {
+		t.Errorf("got %v, want circle", s)
+	}
+	if rng.Int63() != ref.Int63() {
+		t.Errorf("random numbers were consumed")
+	}
+
+	mix := ShapeMix{ShapeCircle: 1, ShapeBezier: 3}
+	counts := make(map[Shape]int)
+	for i := 0; i < 1000; i++ {
+		counts[mix.pick(rng)]++
+	}
+	if len(counts) != 2 || counts[ShapeBezier] < 2*counts[ShapeCircle] {
+		t.Errorf("got shapes %v, want about 250 circles and 750 Bézier", counts)
+	}
+
+	if err := (ShapeMix{ShapeRect: -1}).Validate(); err == nil {
+		t.Errorf("want error for negative frequency")
+	}
+}
+
+func TestShapeJSON(t *testing.T) {
+	img := &ImageDNA{
+		W: 40, H: 40,
+		Polys: []Poly{
+			{Shape: ShapePolygon, Col: color.NRGBA{A: 10}, Pts: []image.Point{{0, 0}, {5, 1}, {2, 8}}},
+			{Shape: ShapeEllipse, Col: color.NRGBA{A: 20}, Pts: []image.Point{{20, 20}, {35, 0}, {20, 25}}},
+		},
+	}
+	buf, err := json.Marshal(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(buf), `"shape"`) != 1 {
+		t.Errorf("only non-polygon shapes should be named: %s", buf)
+	}
+	got := &ImageDNA{}
+	if err = json.Unmarshal(buf, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Polys[0].Shape != ShapePolygon || got.Polys[1].Shape != ShapeEllipse {
+		t.Errorf("got shapes %v, %v", got.Polys[0].Shape, got.Polys[1].Shape)
+	}
+
+	bad := `{"w":10,"h":10,"polys":[{"shape":"circle","col":[0,0,0,0],"pts":[[1,1]]}]}`
+	if err = json.Unmarshal([]byte(bad), got); err == nil {
+		t.Errorf("want error for a circle with a single point")
+	}
+
+	var svg bytes.Buffer
+	if err = img.WriteSVG(&svg); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(svg.String(), `<ellipse cx="20" cy="20" rx="25" ry="5" transform="rotate(-53.1301 20 20)"`) {
+		t.Errorf("got SVG:\n%s", svg.String())
+	}
+}
@@ -0,0 +1,88 @@
+package evolver
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"os"
+
+	"github.com/fogleman/gg"
+)
+
+// WriteSVG writes an SVG document representing the image coded by img, each
+// shape being drawn as a filled SVG element: <polygon>, <circle>, <ellipse>,
+// <rect> or <path> for Bézier shapes.
+func (img *ImageDNA) WriteSVG(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+		img.W, img.H, img.W, img.H)
+	for i := range img.Polys {
+		p := &img.Polys[i]
+		col := color.NRGBAModel.Convert(p.Col).(color.NRGBA)
+		writeSVGShape(bw, p)
+		fmt.Fprintf(bw, ` fill="#%02x%02x%02x" fill-opacity="%.4g"/>`+"\n",
+			col.R, col.G, col.B, float64(col.A)/255)
+	}
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
+
+// writeSVGShape writes the opening of the SVG element drawing p, its name and
+// geometry attributes.
+func writeSVGShape(w *bufio.Writer, p *Poly) {
+	switch p.Shape {
+	case ShapeCircle:
+		c, u, _ := p.axes()
+		fmt.Fprintf(w, `<circle cx="%g" cy="%g" r="%.6g"`, c.X, c.Y, u.Distance(gg.Point{}))
+	case ShapeEllipse:
+		c, u, v := p.axes()
+		fmt.Fprintf(w, `<ellipse cx="%g" cy="%g" rx="%.6g" ry="%.6g" transform="rotate(%.6g %g %g)"`,
+			c.X, c.Y, u.Distance(gg.Point{}), v.Distance(gg.Point{}),
+			math.Atan2(u.Y, u.X)*180/math.Pi, c.X, c.Y)
+	case ShapeRect:
+		r := image.Rectangle{Min: p.Pts[0], Max: p.Pts[1]}.Canon()
+		fmt.Fprintf(w, `<rect x="%d" y="%d" width="%d" height="%d"`, r.Min.X, r.Min.Y, r.Dx(), r.Dy())
+	case ShapeRotatedRect:
+		fmt.Fprint(w, `<polygon points="`)
+		for j, pt := range p.corners() {
+			if j > 0 {
+				w.WriteByte(' ')
+			}
+			fmt.Fprintf(w, "%.6g,%.6g", pt.X, pt.Y)
+		}
+		w.WriteByte('"')
+	case ShapeBezier:
+		fmt.Fprintf(w, `<path d="M%d,%d`, p.Pts[0].X, p.Pts[0].Y)
+		for i := range p.Pts {
+			c1, c2 := p.bezierControls(i)
+			end := p.Pts[(i+1)%len(p.Pts)]
+			fmt.Fprintf(w, " C%.6g,%.6g %.6g,%.6g %d,%d", c1.X, c1.Y, c2.X, c2.Y, end.X, end.Y)
+		}
+		fmt.Fprint(w, ` Z"`)
+	default:
+		fmt.Fprint(w, `<polygon points="`)
+		for j, pt := range p.Pts {
+			if j > 0 {
+				w.WriteByte(' ')
+			}
+			fmt.Fprintf(w, "%d,%d", pt.X, pt.Y)
+		}
+		w.WriteByte('"')
+	}
+}
+
+// SaveSVG writes the SVG document representing img into the file fn.
+func SaveSVG(fn string, img *ImageDNA) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	if err = img.WriteSVG(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,31 @@
+package evolver
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"testing"
+)
+
+func TestWriteSVG(t *testing.T) {
+	img := &ImageDNA{
+		W: 20, H: 10,
+		Polys: []Poly{
+			Poly{
+				Col: color.NRGBA{R: 255, G: 16, B: 0, A: 51},
+				Pts: []image.Point{{0, 0}, {5, 1}, {2, 8}},
+			},
+		},
+	}
+	var buf bytes.Buffer
+	if err := img.WriteSVG(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" width="20" height="10" viewBox="0 0 20 10">
+<polygon points="0,0 5,1 2,8" fill="#ff1000" fill-opacity="0.2"/>
+</svg>
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,145 @@
+package evolver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aurelien-rainone/evolve/framework"
+	"github.com/aurelien-rainone/evolve/termination"
+)
+
+// runLimits returns the termination conditions that stop every stage of a run:
+// ctx being done and the time limit of o.Termination, start being the time the
+// run started.
+func (o *Options) runLimits(ctx context.Context, start time.Time) ([]framework.TerminationCondition, error) {
+	conds := []framework.TerminationCondition{contextDone{ctx}}
+	if t := o.Termination.Time; t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid termination time %q", t)
+		}
+		conds = append(conds, elapsedTime{start: start, max: d})
+	}
+	return conds, nil
+}
+
+// terminationConditions returns the termination conditions of the full
+// resolution evolution configured in o.Termination, in addition to the run
+// limits. offset is the number of the first generation.
+func (o *Options) terminationConditions(ctx context.Context, start time.Time, offset int) ([]framework.TerminationCondition, error) {
+	t := &o.Termination
+	if t.Generations < 0 || t.Stagnation < 0 {
+		return nil, fmt.Errorf("termination generations and stagnation must be positive")
+	}
+	if t.Similarity < 0 || t.Similarity > 100 {
+		return nil, fmt.Errorf("invalid termination similarity %v, want [0, 100]", t.Similarity)
+	}
+
+	conds, err := o.runLimits(ctx, start)
+	if err != nil {
+		return nil, err
+	}
+	conds = append(conds, termination.NewTargetFitness(t.Fitness, false))
+	if t.Generations > 0 {
+		conds = append(conds, generationCount{max: t.Generations, offset: offset})
+	}
+	if t.Stagnation > 0 {
+		conds = append(conds, &stagnation{limit: t.Stagnation})
+	}
+	if t.Similarity > 0 {
+		conds = append(conds, targetSimilarity{metric: o.Fitness.Metric, similarity: t.Similarity})
+	}
+	return conds, nil
+}
+
+// contextDone is a termination condition satisfied when a context is done.
+type contextDone struct {
+	ctx context.Context
+}
+
+func (c contextDone) ShouldTerminate(*framework.PopulationData) bool {
+	return c.ctx.Err() != nil
+}
+
+func (c contextDone) String() string {
+	return "Context done"
+}
+
+// elapsedTime is a termination condition satisfied once a wall-clock duration
+// has elapsed since a starting time.
+type elapsedTime struct {
+	start time.Time
+	max   time.Duration
+}
+
+func (c elapsedTime) ShouldTerminate(*framework.PopulationData) bool {
+	return time.Since(c.start) >= c.max
+}
+
+func (c elapsedTime) String() string {
+	return fmt.Sprintf("Elapsed time %v", c.max)
+}
+
+// generationCount is a termination condition satisfied once a number of
+// generations has been evolved. Generations are numbered from offset.
+type generationCount struct {
+	max    int
+	offset int
+}
+
+func (c generationCount) ShouldTerminate(data *framework.PopulationData) bool {
+	return c.reached(data.GenerationNumber())
+}
+
+// reached reports whether generation gen is the last one.
+func (c generationCount) reached(gen int) bool {
+	return c.offset+gen+1 >= c.max
+}
+
+func (c generationCount) String() string {
+	return fmt.Sprintf("Generation count %v", c.max)
+}
+
+// stagnation is a termination condition satisfied once the best fitness
+// hasn't improved for a number of generations.
+type stagnation struct {
+	limit int
+
+	started bool
+	best    float64 // best fitness so far
+	bestGen int     // generation of the best fitness
+}
+
+func (c *stagnation) ShouldTerminate(data *framework.PopulationData) bool {
+	return c.update(data.GenerationNumber(), data.BestCandidateFitness())
+}
+
+// update records the best fitness of generation gen and reports whether the
+// fitness stagnates.
+func (c *stagnation) update(gen int, fitness float64) bool {
+	if !c.started || fitness < c.best {
+		c.started = true
+		c.best, c.bestGen = fitness, gen
+	}
+	return gen-c.bestGen >= c.limit
+}
+
+func (c *stagnation) String() string {
+	return fmt.Sprintf("Stagnation for %v generations", c.limit)
+}
+
+// targetSimilarity is a termination condition satisfied once the best
+// candidate is similar enough to the reference image (see Similarity).
+type targetSimilarity struct {
+	metric     string
+	similarity float64 // percentage
+}
+
+func (c targetSimilarity) ShouldTerminate(data *framework.PopulationData) bool {
+	return Similarity(c.metric, data.BestCandidateFitness()) >= c.similarity
+}
+
+func (c targetSimilarity) String() string {
+	return fmt.Sprintf("Target similarity %v%%", c.similarity)
+}
@@ -0,0 +1,86 @@
+package evolver
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestGenerationCount(t *testing.T) {
+	if c := (generationCount{max: 3}); c.reached(1) || !c.reached(2) {
+		t.Errorf("want the third generation to be the last one")
+	}
+	// resumed runs count from the checkpointed generation
+	if c := (generationCount{max: 3, offset: 2}); !c.reached(0) {
+		t.Errorf("want the first resumed generation to be the last one")
+	}
+}
+
+func TestStagnation(t *testing.T) {
+	c := &stagnation{limit: 2}
+	stop := -1
+	for gen, f := range []float64{10, 9, 9, 8, 8, 8, 8} {
+		if c.update(gen, f) {
+			stop = gen
+			break
+		}
+	}
+	if stop != 5 {
+		t.Errorf("stopped at generation %v, want 5", stop)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		metric  string
+		fitness float64
+		want    float64
+	}{
+		{MetricMSE, 0, 100},
+		{MetricMSE, 255 * 255, 0},
+		{MetricMSE, 25.5 * 25.5, 90},
+		{"", 51 * 51, 80},
+		{MetricMAE, 25.5, 90},
+		{MetricSSIM, 1.5, 0},
+		{MetricDeltaE, 5, 95},
+		{"foo", 0, 0},
+	}
+	for _, tt := range tests {
+		if got := Similarity(tt.metric, tt.fitness); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Similarity(%q, %v) = %v, want %v", tt.metric, tt.fitness, got, tt.want)
+		}
+	}
+}
+
+func TestTerminationConditions(t *testing.T) {
+	var opts Options
+	conds, err := opts.terminationConditions(context.Background(), time.Now(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conds) != 2 {
+		t.Errorf("got %v default conditions, want context done and target fitness", len(conds))
+	}
+
+	opts.Termination.Generations = 100
+	opts.Termination.Time = "1h30m"
+	opts.Termination.Stagnation = 50
+	opts.Termination.Similarity = 95
+	if conds, err = opts.terminationConditions(context.Background(), time.Now(), 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(conds) != 6 {
+		t.Errorf("got %v conditions, want 6", len(conds))
+	}
+
+	for _, tt := range []struct {
+		time       string
+		similarity float64
+	}{{"1 hour", 0}, {"-1m", 0}, {"", 101}} {
+		opts.Termination.Time, opts.Termination.Similarity = tt.time, tt.similarity
+		if _, err = opts.terminationConditions(context.Background(), time.Now(), 0); err == nil {
+			t.Errorf("want error for time %q and similarity %v", tt.time, tt.similarity)
+		}
+	}
+}
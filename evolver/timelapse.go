@@ -0,0 +1,334 @@
+package evolver
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"io/ioutil"
+	"log"
+	"math"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/aurelien-rainone/evolve/framework"
+	"github.com/fogleman/gg"
+	xdraw "golang.org/x/image/draw"
+)
+
+// TimelapseOptions configures a time-lapse animation.
+type TimelapseOptions struct {
+	Delay   int     // delay between frames, in milliseconds
+	Scale   float64 // scale factor applied to the frames, 0 means 1
+	Overlay bool    // print the generation number and fitness on frames
+
+	// MaxFrames caps the number of frames, 0 for no limit. Once reached,
+	// every other frame is dropped, and only one out of two frames added
+	// next is kept, and so on.
+	MaxFrames int
+}
+
+// Timelapse is an animated GIF showing the evolution of the best candidate.
+type Timelapse struct {
+	opts   TimelapseOptions
+	anim   gif.GIF
+	stride int // one out of stride added frames is kept
+	added  int // number of frames added
+}
+
+// NewTimelapse creates an empty time-lapse animation.
+func NewTimelapse(opts TimelapseOptions) (*Timelapse, error) {
+	if opts.Delay < 0 {
+		return nil, fmt.Errorf("invalid time-lapse frame delay %v", opts.Delay)
+	}
+	if opts.Scale < 0 {
+		return nil, fmt.Errorf("invalid time-lapse scale %v", opts.Scale)
+	}
+	if opts.MaxFrames < 0 || opts.MaxFrames == 1 {
+		return nil, fmt.Errorf("invalid time-lapse maximum number of frames %v", opts.MaxFrames)
+	}
+	if opts.Scale == 0 {
+		opts.Scale = 1
+	}
+	return &Timelapse{opts: opts, stride: 1}, nil
+}
+
+// Len returns the number of frames of the animation.
+func (t *Timelapse) Len() int {
+	return len(t.anim.Image)
+}
+
+// AddImage appends img to the animation, scaling it if needed. fitness is only
+// printed if it's not NaN.
+func (t *Timelapse) AddImage(img image.Image, generation int, fitness float64) {
+	if !t.keep() {
+		return
+	}
+	if t.opts.Scale != 1 {
+		b := img.Bounds()
+		scaled := image.NewRGBA(image.Rect(0, 0, t.scaled(b.Dx()), t.scaled(b.Dy())))
+		xdraw.CatmullRom.Scale(scaled, scaled.Bounds(), img, b, draw.Src, nil)
+		img = scaled
+	}
+	t.addFrame(img, generation, fitness)
+}
+
+// AddGenome renders dna at the animation scale, and appends it.
+func (t *Timelapse) AddGenome(dna *ImageDNA, generation int, fitness float64) {
+	if t.keep() {
+		t.addGenome(dna, generation, fitness)
+	}
+}
+
+// addGenome renders dna and appends it, whatever the current stride.
+func (t *Timelapse) addGenome(dna *ImageDNA, generation int, fitness float64) {
+	t.addFrame(dna.RenderSize(t.scaled(dna.W), t.scaled(dna.H)), generation, fitness)
+}
+
+// keep counts an added frame, and reports whether it's kept given the
+// current stride.
+func (t *Timelapse) keep() bool {
+	k := t.added%t.stride == 0
+	t.added++
+	return k
+}
+
+func (t *Timelapse) scaled(n int) int {
+	return int(math.Max(1, math.Round(float64(n)*t.opts.Scale)))
+}
+
+func (t *Timelapse) addFrame(img image.Image, generation int, fitness float64) {
+	if t.opts.Overlay {
+		img = overlay(img, generation, fitness)
+	}
+	if t.opts.MaxFrames > 0 && t.Len() >= t.opts.MaxFrames {
+		t.decimate()
+	}
+	b := img.Bounds()
+	frame := image.NewPaletted(image.Rect(0, 0, b.Dx(), b.Dy()), palette.Plan9)
+	draw.FloydSteinberg.Draw(frame, frame.Bounds(), img, b.Min)
+
+	t.anim.Image = append(t.anim.Image, frame)
+	t.anim.Delay = append(t.anim.Delay, t.opts.Delay/10)
+}
+
+// decimate drops every other frame, and doubles the stride of the frames
+// kept next.
+func (t *Timelapse) decimate() {
+	n := 0
+	for i := 0; i < t.Len(); i += 2 {
+		t.anim.Image[n], t.anim.Delay[n] = t.anim.Image[i], t.anim.Delay[i]
+		n++
+	}
+	for i := n; i < t.Len(); i++ {
+		// release dropped frames
+		t.anim.Image[i] = nil
+	}
+	t.anim.Image, t.anim.Delay = t.anim.Image[:n], t.anim.Delay[:n]
+	t.stride *= 2
+}
+
+// overlay returns a copy of img with the generation number and the fitness
+// printed in its lower left corner.
+func overlay(img image.Image, generation int, fitness float64) image.Image {
+	text := fmt.Sprintf("gen %d", generation)
+	if !math.IsNaN(fitness) {
+		text += fmt.Sprintf(" fitness %.2f", fitness)
+	}
+
+	dc := gg.NewContextForImage(img)
+	tw, th := dc.MeasureString(text)
+	h := float64(dc.Height())
+	dc.SetColor(color.NRGBA{A: 128})
+	dc.DrawRectangle(0, h-th-6, tw+6, th+6)
+	dc.Fill()
+	dc.SetColor(color.White)
+	dc.DrawString(text, 3, h-4)
+	return dc.Image()
+}
+
+// Encode writes the animation as a GIF into w.
+func (t *Timelapse) Encode(w io.Writer) error {
+	if t.Len() == 0 {
+		return fmt.Errorf("time-lapse has no frames")
+	}
+	return gif.EncodeAll(w, &t.anim)
+}
+
+// Save writes the animation as a GIF into the file fn.
+func (t *Timelapse) Save(fn string) error {
+	f, err := os.Create(fn)
+	if err != nil {
+		return err
+	}
+	if err = t.Encode(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// snapshotRe matches the names of snapshots saved with the default pattern.
+var snapshotRe = regexp.MustCompile(`^(\d+)\.(png|jpg)$`)
+
+// TimelapseFromDir creates a time-lapse animation of the evolution run which
+// output directory is dir.
+//
+// Frames are rendered from the genomes recorded in the evolution database. If
+// there is no database, the snapshots saved with the default naming pattern
+// are used instead.
+func TimelapseFromDir(dir string, opts TimelapseOptions) (*Timelapse, error) {
+	t, err := NewTimelapse(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = os.Stat(path.Join(dir, dbName)); err == nil {
+		err = readBestGenomes(dir, func(generation int, fitness float64, dna *ImageDNA) error {
+			t.AddGenome(dna, generation, fitness)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return t, nil
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	type snapshot struct {
+		fn         string
+		generation int
+	}
+	var snapshots []snapshot
+	for _, fi := range files {
+		if m := snapshotRe.FindStringSubmatch(fi.Name()); m != nil {
+			generation, _ := strconv.Atoi(m[1])
+			snapshots = append(snapshots, snapshot{fi.Name(), generation})
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].generation < snapshots[j].generation })
+
+	for _, s := range snapshots {
+		img, err := readImage(path.Join(dir, s.fn))
+		if err != nil {
+			return nil, err
+		}
+		t.AddImage(img, s.generation, math.NaN())
+	}
+	return t, nil
+}
+
+// readImage decodes the image file fn.
+func readImage(fn string) (image.Image, error) {
+	f, err := os.Open(fn)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode %v: %v", fn, err)
+	}
+	return img, nil
+}
+
+// timelapseBacklog is the number of frames waiting to be rendered before the
+// time-lapse observer blocks the evolution.
+const timelapseBacklog = 16
+
+// timelapseFrame is a best candidate to add to a time-lapse animation.
+type timelapseFrame struct {
+	dna        *ImageDNA
+	generation int
+	fitness    float64
+}
+
+// timelapseObserver assembles the best candidates into a time-lapse animation,
+// saved when the evolution ends. Frames are rendered and dithered by a
+// goroutine, off the evolution loop.
+type timelapseObserver struct {
+	freq   int    // add a frame every N generations
+	fn     string // animation file
+	offset int    // generation number of the resumed checkpoint
+
+	t        *Timelapse          // owned by the rendering goroutine until done
+	frames   chan timelapseFrame // frames to render
+	done     chan struct{}       // closed once all frames are rendered
+	rendered int                 // generation of the last rendered frame
+
+	mu   sync.Mutex
+	last timelapseFrame // best candidate of the last generation
+}
+
+func newTimelapseObserver(freq int, fn string, opts TimelapseOptions, offset int) (*timelapseObserver, error) {
+	if freq == 0 {
+		return nil, fmt.Errorf("timelapseObserver frequency can't be 0")
+	}
+	t, err := NewTimelapse(opts)
+	if err != nil {
+		return nil, err
+	}
+	o := &timelapseObserver{
+		freq:     freq,
+		fn:       fn,
+		offset:   offset,
+		t:        t,
+		frames:   make(chan timelapseFrame, timelapseBacklog),
+		done:     make(chan struct{}),
+		rendered: -1,
+	}
+	go o.render()
+	return o, nil
+}
+
+func (o *timelapseObserver) PopulationUpdate(data *framework.PopulationData) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.last = timelapseFrame{
+		dna:        data.BestCandidate().(*ImageDNA),
+		generation: o.offset + data.GenerationNumber(),
+		fitness:    data.BestCandidateFitness(),
+	}
+	if o.last.generation%o.freq == 0 {
+		o.frames <- o.last
+	}
+}
+
+// render adds the frames sent by PopulationUpdate to the animation.
+func (o *timelapseObserver) render() {
+	defer close(o.done)
+	for f := range o.frames {
+		if o.t.keep() {
+			o.t.addGenome(f.dna, f.generation, f.fitness)
+			o.rendered = f.generation
+		}
+	}
+}
+
+// close waits for pending frames, adds the last generation to the animation,
+// and saves it.
+func (o *timelapseObserver) close() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	close(o.frames)
+	<-o.done
+	if o.last.dna != nil && o.rendered != o.last.generation {
+		o.t.addGenome(o.last.dna, o.last.generation, o.last.fitness)
+	}
+	if o.t.Len() == 0 {
+		return
+	}
+	if err := o.t.Save(o.fn); err != nil {
+		log.Println("couldn't save time-lapse:", err)
+	}
+}
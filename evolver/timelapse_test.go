@@ -0,0 +1,138 @@
+package evolver
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"io/ioutil"
+	"math"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/aurelien-rainone/evolve/framework"
+)
+
+func TestTimelapseFromSnapshots(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timelapse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	img := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for _, gen := range []string{"0", "1000", "200"} {
+		if err = SaveImage(path.Join(dir, gen), img, OutputPNG, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// files that aren't snapshots are ignored
+	if err = saveToPng(path.Join(dir, "_ref.png"), img); err != nil {
+		t.Fatal(err)
+	}
+
+	tl, err := TimelapseFromDir(dir, TimelapseOptions{Delay: 200, Scale: 0.5, Overlay: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = tl.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(anim.Image) != 3 {
+		t.Fatalf("got %v frames, want 3", len(anim.Image))
+	}
+	for i, frame := range anim.Image {
+		if frame.Bounds() != image.Rect(0, 0, 20, 10) {
+			t.Errorf("frame %v: got bounds %v, want 20x10", i, frame.Bounds())
+		}
+		if anim.Delay[i] != 20 {
+			t.Errorf("frame %v: got delay %v, want 20", i, anim.Delay[i])
+		}
+	}
+}
+
+func TestTimelapseGenomes(t *testing.T) {
+	tl, err := NewTimelapse(TimelapseOptions{Scale: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dna := &ImageDNA{
+		W: 10, H: 8,
+		Polys: []Poly{{Col: color.NRGBA{R: 255, A: 255}, Pts: []image.Point{{0, 0}, {10, 0}, {10, 8}, {0, 8}}}},
+	}
+	tl.AddGenome(dna, 100, 1.5)
+	tl.AddImage(dna.Render(), 200, math.NaN())
+	if tl.Len() != 2 {
+		t.Fatalf("got %v frames, want 2", tl.Len())
+	}
+	for i, frame := range tl.anim.Image {
+		if frame.Bounds() != image.Rect(0, 0, 20, 16) {
+			t.Errorf("frame %v: got bounds %v, want 20x16", i, frame.Bounds())
+		}
+		if r, g, _, _ := frame.At(10, 8).RGBA(); r < 0xf000 || g > 0x1000 {
+			t.Errorf("frame %v: got %v, want red", i, frame.At(10, 8))
+		}
+	}
+
+	if _, err = NewTimelapse(TimelapseOptions{Delay: -1}); err == nil {
+		t.Errorf("want error for a negative delay")
+	}
+	if _, err = NewTimelapse(TimelapseOptions{MaxFrames: 1}); err == nil {
+		t.Errorf("want error for a single frame")
+	}
+}
+
+func TestTimelapseDecimation(t *testing.T) {
+	tl, err := NewTimelapse(TimelapseOptions{MaxFrames: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	// frames are kept with a stride of 1 up to 4 frames, then 2 and 4
+	want := []int{1, 2, 3, 4, 3, 3, 4, 4, 3, 3, 3, 3, 4}
+	for i, n := range want {
+		tl.AddImage(img, i, math.NaN())
+		if tl.Len() != n {
+			t.Fatalf("after frame %v: got %v frames, want %v", i, tl.Len(), n)
+		}
+	}
+}
+
+func TestTimelapseObserver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "timelapse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fn := path.Join(dir, "timelapse.gif")
+	o, err := newTimelapseObserver(2, fn, TimelapseOptions{MaxFrames: 4}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dna := &ImageDNA{W: 10, H: 8}
+	for gen := 0; gen < 20; gen++ {
+		o.PopulationUpdate(framework.NewPopulationData(dna, 1, 1, 0, false, 1, 0, gen, 0))
+	}
+	o.close()
+
+	f, err := os.Open(fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	anim, err := gif.DecodeAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// generations 0, 8 and 16 are sampled, then the last one is added
+	if len(anim.Image) != 4 {
+		t.Errorf("got %v frames, want 4", len(anim.Image))
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync"
+
+	"github.com/aurelien-rainone/artificial/evolver"
+)
+
+// runJobs calls fn for each job index in [0, n), running at most jobs of them
+// concurrently, and waits for all of them to return.
+func runJobs(ctx context.Context, n, jobs int, fn func(ctx context.Context, i int)) {
+	sem := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			fn(ctx, i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// shareWorkers shares the CPU cores between concurrent jobs, by limiting the
+// number of fitness workers of opts, unless it's been configured.
+func shareWorkers(opts *evolver.Options, jobs int) {
+	if jobs > 1 && opts.Fitness.Workers == 0 {
+		opts.Fitness.Workers = max(1, runtime.NumCPU()/jobs)
+	}
+}
+
+// hasTermination reports whether opts has a termination condition, runs
+// without any only stopping when interrupted.
+func hasTermination(opts *evolver.Options) bool {
+	t := opts.Termination
+	return t.Generations != 0 || t.Time != "" || t.Stagnation != 0 || t.Similarity != 0
+}
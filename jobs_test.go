@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aurelien-rainone/artificial/evolver"
+)
+
+func TestRunJobs(t *testing.T) {
+	const n, jobs = 20, 3
+	var (
+		mu            sync.Mutex
+		running, peak int
+		done          [n]bool
+	)
+	runJobs(context.Background(), n, jobs, func(ctx context.Context, i int) {
+		mu.Lock()
+		running++
+		peak = max(peak, running)
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running--
+		done[i] = true
+		mu.Unlock()
+	})
+	for i, d := range done {
+		if !d {
+			t.Errorf("job %v didn't run", i)
+		}
+	}
+	if peak > jobs {
+		t.Errorf("%v jobs ran concurrently, want at most %v", peak, jobs)
+	}
+}
+
+func TestShareWorkers(t *testing.T) {
+	var opts evolver.Options
+	shareWorkers(&opts, 1)
+	if opts.Fitness.Workers != 0 {
+		t.Errorf("single job workers = %v, want 0", opts.Fitness.Workers)
+	}
+	shareWorkers(&opts, 2*runtime.NumCPU())
+	if opts.Fitness.Workers != 1 {
+		t.Errorf("workers = %v, want 1", opts.Fitness.Workers)
+	}
+	opts.Fitness.Workers = 5
+	shareWorkers(&opts, 2)
+	if opts.Fitness.Workers != 5 {
+		t.Errorf("configured workers = %v, want 5", opts.Fitness.Workers)
+	}
+}
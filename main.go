@@ -1,22 +1,24 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"image"
-	"image/png"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"io/ioutil"
 	"log"
-	"math/rand"
+	"math"
 	"os"
 	"os/signal"
 	"path"
 	"runtime/pprof"
-	"time"
 
-	"github.com/aurelien-rainone/evolve"
-	"github.com/aurelien-rainone/evolve/operators"
-	"github.com/aurelien-rainone/evolve/selection"
-	"github.com/aurelien-rainone/evolve/termination"
+	"github.com/aurelien-rainone/artificial/evolver"
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
 )
 
 func check(err error) {
@@ -26,6 +28,20 @@ func check(err error) {
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "timelapse":
+			check(timelapseCmd(os.Args[2:]))
+			return
+		case "batch":
+			check(batchCmd(os.Args[2:]))
+			return
+		case "sweep":
+			check(sweepCmd(os.Args[2:]))
+			return
+		}
+	}
+
 	err := readConfig()
 	check(err)
 
@@ -39,139 +55,115 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	fmt.Println("Reference image:", appConfig.RefImage)
-	f, err := os.Open(appConfig.RefImage)
-	check(err)
-	defer f.Close()
-
-	img, err := png.Decode(f)
-	check(err)
-	bestImg, err := evolveImage(convertToRGBA(img))
-	check(err)
-
-	// save best candidate
-	err = saveToPng("best.png", bestImg)
-	check(err)
-}
-
-func saveToPng(fn string, img image.Image) error {
-	f, err := os.Create(fn)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	png.Encode(f, img)
-	return nil
-}
-
-// convert any image.Image into *image.RGBA
-func convertToRGBA(img image.Image) *image.RGBA {
-	var rgba *image.RGBA
-
-	switch cimg := img.(type) {
-	case *image.RGBA:
-		// nothing to do
-		rgba = cimg
-	default:
-		b := img.Bounds()
-		rgba = image.NewRGBA(b)
-		// convert pixel by pixel
-		for y := 0; y < b.Max.Y; y++ {
-			for x := 0; x < b.Max.X; x++ {
-				col := img.At(x, y)
-				rgba.Set(x, y, col)
-			}
+	if *renderDir != "" {
+		// only render the best checkpointed candidate, or the best candidate
+		// of a past generation
+		var best *evolver.ImageDNA
+		if *renderGen >= 0 {
+			best, err = evolver.ReadGenome(*renderDir, *renderGen)
+			check(err)
+		} else {
+			ckpt, err := evolver.ReadCheckpoint(*renderDir)
+			check(err)
+			best = ckpt.Best
 		}
-	}
-	return rgba
-}
-
-func evolveImage(img *image.RGBA) (image.Image, error) {
-	// pseudo random number generator
-	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
-
-	// chromosome/image factory
-	DNAFactory, err := newImageDNAfactory(img.Bounds().Dx(), img.Bounds().Dy())
-	if err != nil {
-		return nil, err
+		check(saveBest("", best, &appConfig))
+		return
 	}
 
-	// mutation settings
-	mutation, err := newImageDNAMutation()
+	fmt.Println("Reference image:", appConfig.RefImage)
+	img, err := readRefImage(appConfig.RefImage)
 	if err != nil {
-		return nil, err
+		log.Fatal(err)
 	}
 
-	// crossover settings
-	crossover, err := newImageDNACrossover()
-	if err != nil {
-		return nil, err
+	if *resumeDir != "" {
+		log.Println("resuming from checkpoint in:", *resumeDir)
+		appConfig.Resume, err = evolver.ReadCheckpoint(*resumeDir)
+		check(err)
 	}
 
-	// create a pipeline that applies mutation then crossover
-	pipeline, err := operators.NewEvolutionPipeline(mutation, crossover)
-	check(err)
-
-	// define a selection strategy
-	selectionStrategy := selection.Identity{}
-
-	// define a fitness evaluator
-	evaluator := &fitnessEvaluator{img}
-
-	engine := evolve.NewGenerationalEvolutionEngine(DNAFactory,
-		pipeline,
-		evaluator,
-		selectionStrategy,
-		rng)
-
-	// define termination conditions
-	userAbort := termination.NewUserAbort()
-	targetFitness := termination.NewTargetFitness(0, false)
-
 	// define output directory for saves images and generations database
-	outDir, err := ioutil.TempDir("_output", "")
-	if err != nil {
-		return nil, fmt.Errorf("output directory error:, %v", err)
+	if appConfig.Observe.OutDir == "" {
+		appConfig.Observe.OutDir, err = ioutil.TempDir("_output", "")
+		if err != nil {
+			log.Fatalf("output directory error: %v", err)
+		}
 	}
-	log.Println("ouput directory:", outDir)
 
-	// define evolution observers
-	bestObs, err := newBestObserver(100, outDir)
-	if err != nil {
-		return nil, err
-	}
-	engine.AddEvolutionObserver(bestObs)
+	ctx := interruptContext()
+	res, err := evolver.Run(ctx, img, appConfig)
+	check(err)
 
-	sqliteObs, err := newSqliteObserver(100, outDir)
-	if err != nil {
-		return nil, err
+	fmt.Println("Evolution ended...")
+	for _, cond := range res.Satisfied {
+		fmt.Println(cond)
 	}
-	defer sqliteObs.close()
-	engine.AddEvolutionObserver(sqliteObs)
 
-	// save a copy of refernce image in output dir
-	saveToPng(path.Join(outDir, "_ref.png"), img)
+	// save best candidate
+	check(saveBest("", res.Best, &appConfig))
+}
 
+// interruptContext returns a context canceled when the user interrupts the
+// program.
+func interruptContext() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
 		// handle user termination
 		sigchan := make(chan os.Signal, 1)
 		signal.Notify(sigchan, os.Interrupt)
 		<-sigchan
-		userAbort.Abort()
+		cancel()
 	}()
+	return ctx
+}
 
-	best := engine.Evolve(
-		appConfig.Population.NumIndividuals,
-		appConfig.Population.EliteCount,
-		userAbort, targetFitness)
-
-	satisfied, err := engine.SatisfiedTerminationConditions()
+// readRefImage reads and decodes the reference image fn.
+func readRefImage(fn string) (image.Image, error) {
+	f, err := os.Open(fn)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("Evolution ended...")
-	for _, cond := range satisfied {
-		fmt.Println(cond)
+	defer f.Close()
+
+	img, format, err := image.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("can't decode reference image %q: %v", fn, err)
+	}
+	log.Println("reference image format:", format)
+	return img, nil
+}
+
+// saveBest saves best in dir as best.png (or the output format of opts),
+// rendered at the dimensions requested on the command line, and as best.svg.
+func saveBest(dir string, best *evolver.ImageDNA, opts *evolver.Options) error {
+	w, h, err := renderDims(best.W, best.H)
+	if err != nil {
+		return err
+	}
+	err = evolver.SaveImage(path.Join(dir, "best"), best.RenderSize(w, h), opts.Output.Format, opts.Output.Quality)
+	if err != nil {
+		return err
+	}
+	return evolver.SaveSVG(path.Join(dir, "best.svg"), best)
+}
+
+// renderDims returns the dimensions at which a w x h candidate should be
+// rendered, according to the -render-scale and -render-size flags.
+func renderDims(w, h int) (int, int, error) {
+	switch {
+	case *renderSize != "" && *renderScale != 0:
+		return 0, 0, fmt.Errorf("-render-size and -render-scale are mutually exclusive")
+	case *renderSize != "":
+		var rw, rh int
+		if _, err := fmt.Sscanf(*renderSize, "%dx%d", &rw, &rh); err != nil || rw <= 0 || rh <= 0 {
+			return 0, 0, fmt.Errorf("invalid render size %q, want WxH", *renderSize)
+		}
+		return rw, rh, nil
+	case *renderScale < 0:
+		return 0, 0, fmt.Errorf("invalid render scale %v", *renderScale)
+	case *renderScale > 0:
+		return int(math.Round(float64(w) * *renderScale)), int(math.Round(float64(h) * *renderScale)), nil
 	}
-	return best.(*imageDNA).render(), nil
+	return w, h, nil
 }
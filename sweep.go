@@ -0,0 +1,523 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"log"
+	"math"
+	"math/rand"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aurelien-rainone/artificial/evolver"
+	"github.com/aurelien-rainone/evolve/framework"
+	cfg "github.com/jinzhu/configor"
+)
+
+// names of the sweep search strategies
+const (
+	searchGrid   = "grid"   // every combination of the parameter values
+	searchRandom = "random" // random combinations of the parameter values
+)
+
+// sweepParam is a swept configuration key, taking either listed values or
+// values in a range.
+type sweepParam struct {
+	key            string
+	values         []interface{} // listed values, nil for a range
+	min, max, step float64       // range, step being 0 for a continuous range
+}
+
+// paramFlags collects the -p flags of the sweep subcommand.
+type paramFlags []sweepParam
+
+func (p *paramFlags) String() string {
+	var keys []string
+	for _, param := range *p {
+		keys = append(keys, param.key)
+	}
+	return strings.Join(keys, " ")
+}
+
+func (p *paramFlags) Set(s string) error {
+	param, err := parseSweepParam(s)
+	if err != nil {
+		return err
+	}
+	for _, other := range *p {
+		if other.key == param.key {
+			return fmt.Errorf("parameter %q is swept twice", param.key)
+		}
+	}
+	*p = append(*p, param)
+	return nil
+}
+
+// parseSweepParam parses a swept parameter, given as key=v1,v2,... or
+// key=min:max[:step]. The seed can't be swept.
+func parseSweepParam(s string) (sweepParam, error) {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return sweepParam{}, fmt.Errorf("invalid parameter %q, want key=values", s)
+	}
+	param := sweepParam{key: strings.ToLower(s[:i])}
+	if param.key == "seed" {
+		// each run has its own seed, see the -seed and -repeats flags
+		return param, fmt.Errorf("seed can't be swept, use -seed and -repeats")
+	}
+	vals := s[i+1:]
+	if !strings.Contains(vals, ":") {
+		for _, v := range strings.Split(vals, ",") {
+			param.values = append(param.values, parseValue(v))
+		}
+		return param, nil
+	}
+
+	bounds := strings.Split(vals, ":")
+	if len(bounds) > 3 {
+		return param, fmt.Errorf("invalid range %q, want min:max[:step]", vals)
+	}
+	var fs [3]float64
+	for j, b := range bounds {
+		f, err := strconv.ParseFloat(b, 64)
+		if err != nil {
+			return param, fmt.Errorf("invalid range %q: %v", vals, err)
+		}
+		fs[j] = f
+	}
+	param.min, param.max, param.step = fs[0], fs[1], fs[2]
+	if param.max < param.min || param.step < 0 {
+		return param, fmt.Errorf("invalid range %q", vals)
+	}
+	return param, nil
+}
+
+// grid returns the values of p on a grid search.
+func (p *sweepParam) grid() ([]interface{}, error) {
+	if p.values != nil {
+		return p.values, nil
+	}
+	if p.step == 0 {
+		return nil, fmt.Errorf("grid search of %v needs a range step", p.key)
+	}
+	var vals []interface{}
+	for i := 0; ; i++ {
+		v := roundValue(p.min + float64(i)*p.step)
+		if v > p.max {
+			break
+		}
+		vals = append(vals, v)
+	}
+	return vals, nil
+}
+
+// random returns a random value of p.
+func (p *sweepParam) random(rng *rand.Rand) interface{} {
+	if p.values != nil {
+		return p.values[rng.Intn(len(p.values))]
+	}
+	v := p.min + rng.Float64()*(p.max-p.min)
+	if p.step > 0 {
+		v = math.Min(p.max, p.min+math.Round((v-p.min)/p.step)*p.step)
+	}
+	return roundValue(v)
+}
+
+// roundValue rounds v to 12 significant digits, to get rid of floating-point
+// errors accumulated on range values.
+func roundValue(v float64) float64 {
+	r, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 12, 64), 64)
+	return r
+}
+
+// sweepConfigs returns the configurations, as values of params, to evaluate
+// with the given search strategy.
+func sweepConfigs(params []sweepParam, search string, samples int, rng *rand.Rand) ([][]interface{}, error) {
+	switch search {
+	case searchGrid:
+		configs := [][]interface{}{nil}
+		for i := range params {
+			vals, err := params[i].grid()
+			if err != nil {
+				return nil, err
+			}
+			// cartesian product
+			var next [][]interface{}
+			for _, c := range configs {
+				for _, v := range vals {
+					next = append(next, append(append([]interface{}{}, c...), v))
+				}
+			}
+			configs = next
+		}
+		return configs, nil
+	case searchRandom:
+		if samples < 1 {
+			return nil, fmt.Errorf("invalid number of samples %v", samples)
+		}
+		configs := make([][]interface{}, samples)
+		for i := range configs {
+			for j := range params {
+				configs[i] = append(configs[i], params[j].random(rng))
+			}
+		}
+		return configs, nil
+	}
+	return nil, fmt.Errorf("unknown search strategy %q", search)
+}
+
+// sweepCmd implements the sweep subcommand, that evolves a reference image
+// with different configurations and seeds, and records how the best fitness
+// of each run improves over generations and wall-clock time.
+func sweepCmd(args []string) error {
+	var params paramFlags
+	fs := flag.NewFlagSet("sweep", flag.ExitOnError)
+	configFile := fs.String("cfg", "config.yml", "base configuration file")
+	refImage := fs.String("img", "", "reference image (default: the configured one)")
+	out := fs.String("o", "_sweep", "output directory")
+	search := fs.String("search", searchGrid, "search strategy: grid or random")
+	samples := fs.Int("samples", 10, "number of configurations of a random search")
+	repeats := fs.Int("repeats", 3, "number of runs of each configuration, with different seeds")
+	seed := fs.Int64("seed", 1, "seed of the first run of each configuration, and of the random search (runs r of a configuration use seed+r)")
+	freq := fs.Int("freq", 10, "record the best fitness every N generations")
+	format := fs.String("format", "csv", "results format: csv or sqlite")
+	jobs := fs.Int("j", 1, "number of runs evolved concurrently")
+	fs.Var(&params, "p", "swept configuration key, as key=v1,v2,... or key=min:max[:step] (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s sweep [flags] -p key=values [-p key=values...]\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if len(params) == 0 || fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	switch {
+	case *repeats < 1:
+		return fmt.Errorf("invalid number of repeats %v", *repeats)
+	case *freq < 1:
+		return fmt.Errorf("invalid recording frequency %v", *freq)
+	case *jobs < 1:
+		return fmt.Errorf("invalid number of concurrent jobs %v", *jobs)
+	case *format != "csv" && *format != "sqlite":
+		return fmt.Errorf("unknown results format %q", *format)
+	}
+
+	var base evolver.Options
+	if err := cfg.Load(&base, *configFile); err != nil {
+		return fmt.Errorf("read config error: %v", err)
+	}
+	if *refImage != "" {
+		base.RefImage = *refImage
+	}
+	img, err := readRefImage(base.RefImage)
+	if err != nil {
+		return err
+	}
+
+	configs, err := sweepConfigs(params, *search, *samples, rand.New(rand.NewSource(*seed)))
+	if err != nil {
+		return err
+	}
+	var runs []*sweepRun
+	for i, values := range configs {
+		overrides := make(map[string]interface{})
+		for j, p := range params {
+			overrides[p.key] = values[j]
+		}
+		opts, err := overrideOptions(base, overrides)
+		if err != nil {
+			return fmt.Errorf("configuration %v: %v", i, err)
+		}
+		if err = validateOutput(&opts); err != nil {
+			return fmt.Errorf("configuration %v: %v", i, err)
+		}
+		if !hasTermination(&opts) {
+			return fmt.Errorf("configuration %v: sweep runs need a termination condition", i)
+		}
+		if opts.Observe.HTTP.Enabled {
+			return fmt.Errorf("configuration %v: the http observer can't be enabled in sweeps", i)
+		}
+		for r := 0; r < *repeats; r++ {
+			run := &sweepRun{config: i, values: values, opts: opts}
+			run.opts.Seed = *seed + int64(r)
+			run.opts.Observe.OutDir = path.Join(*out, fmt.Sprintf("%03d-%d", i, run.opts.Seed))
+			shareWorkers(&run.opts, *jobs)
+			run.progress = &progressRecorder{freq: *freq}
+			run.opts.Observers = append(run.opts.Observers[:len(run.opts.Observers):len(run.opts.Observers)], run.progress)
+			runs = append(runs, run)
+		}
+	}
+	log.Printf("sweeping %v configurations, %v runs", len(configs), len(runs))
+
+	ctx := interruptContext()
+	runJobs(ctx, len(runs), *jobs, func(ctx context.Context, i int) {
+		runs[i].evolve(ctx, img)
+	})
+
+	printSweepRanking(params, configs, runs)
+	if *format == "sqlite" {
+		return writeSweepDB(path.Join(*out, "sweep.db"), params, runs)
+	}
+	return writeSweepCSV(*out, params, runs)
+}
+
+// sweepRun is a run of a sweep.
+type sweepRun struct {
+	config   int           // configuration number
+	values   []interface{} // values of the swept parameters
+	opts     evolver.Options
+	progress *progressRecorder
+
+	res      *evolver.Result
+	err      error
+	skipped  bool // interrupted before the run started
+	duration time.Duration
+}
+
+// evolve evolves ref with the run configuration.
+func (r *sweepRun) evolve(ctx context.Context, ref image.Image) {
+	if ctx.Err() != nil {
+		r.skipped = true
+		return
+	}
+	log.Printf("configuration %v, seed %v", r.config, r.opts.Seed)
+	r.progress.start = time.Now()
+	r.res, r.err = evolver.Run(ctx, ref, r.opts)
+	r.duration = time.Since(r.progress.start)
+	if r.err == nil {
+		r.err = saveBest(r.opts.Observe.OutDir, r.res.Best, &r.opts)
+	}
+}
+
+// status returns the status of the run, as reported in results.
+func (r *sweepRun) status() string {
+	switch {
+	case r.err != nil:
+		return r.err.Error()
+	case r.skipped:
+		return "skipped"
+	}
+	return "ok"
+}
+
+// progressSample is the best fitness of a run at a given generation and time.
+type progressSample struct {
+	generation int
+	elapsed    time.Duration // since the start of the run
+	fitness    float64
+}
+
+// progressRecorder is an evolution observer recording the best fitness every
+// freq generations.
+type progressRecorder struct {
+	freq  int
+	start time.Time
+
+	mu      sync.Mutex
+	samples []progressSample
+}
+
+func (r *progressRecorder) PopulationUpdate(data *framework.PopulationData) {
+	if data.GenerationNumber()%r.freq != 0 {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.samples = append(r.samples, progressSample{
+		generation: data.GenerationNumber(),
+		elapsed:    time.Since(r.start),
+		fitness:    data.BestCandidateFitness(),
+	})
+}
+
+// printSweepRanking prints the configurations by increasing mean final
+// fitness of their runs.
+func printSweepRanking(params []sweepParam, configs [][]interface{}, runs []*sweepRun) {
+	type ranked struct {
+		config int
+		mean   float64
+		n      int
+	}
+	ranks := make([]ranked, len(configs))
+	for i := range ranks {
+		ranks[i].config = i
+	}
+	for _, r := range runs {
+		if r.res != nil && r.err == nil {
+			ranks[r.config].mean += r.res.Fitness
+			ranks[r.config].n++
+		}
+	}
+	for i := range ranks {
+		if ranks[i].n > 0 {
+			ranks[i].mean /= float64(ranks[i].n)
+		} else {
+			ranks[i].mean = math.Inf(1)
+		}
+	}
+	sort.SliceStable(ranks, func(i, j int) bool { return ranks[i].mean < ranks[j].mean })
+
+	fmt.Println("Configurations by mean final fitness:")
+	for _, rk := range ranks {
+		var kv []string
+		for j, p := range params {
+			kv = append(kv, fmt.Sprintf("%v=%v", p.key, configs[rk.config][j]))
+		}
+		fmt.Printf("  %03d: %v over %v runs, %v\n", rk.config, rk.mean, rk.n, strings.Join(kv, " "))
+	}
+}
+
+// writeSweepCSV writes the results of a sweep in dir: runs.csv has a row per
+// run, samples.csv a row per recorded generation of each run.
+func writeSweepCSV(dir string, params []sweepParam, runs []*sweepRun) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	write := func(fn string, header []string, rows func(w *csv.Writer)) error {
+		f, err := os.Create(path.Join(dir, fn))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w := csv.NewWriter(f)
+		w.Write(header)
+		rows(w)
+		w.Flush()
+		if err = w.Error(); err != nil {
+			return fmt.Errorf("can't write %v: %v", fn, err)
+		}
+		return nil
+	}
+
+	// columns identifying a run
+	header := []string{"config", "seed"}
+	for _, p := range params {
+		header = append(header, p.key)
+	}
+	runCols := func(r *sweepRun) []string {
+		cols := []string{fmt.Sprint(r.config), fmt.Sprint(r.opts.Seed)}
+		for _, v := range r.values {
+			cols = append(cols, fmt.Sprint(v))
+		}
+		return cols
+	}
+
+	err := write("runs.csv", append(header[:len(header):len(header)], "status", "generations", "fitness", "duration_s"),
+		func(w *csv.Writer) {
+			for _, r := range runs {
+				row := append(runCols(r), r.status(), "", "", "")
+				if r.res != nil && r.err == nil {
+					n := len(row)
+					row[n-3] = fmt.Sprint(r.res.Generation)
+					row[n-2] = fmt.Sprint(r.res.Fitness)
+					row[n-1] = fmt.Sprintf("%.3f", r.duration.Seconds())
+				}
+				w.Write(row)
+			}
+		})
+	if err != nil {
+		return err
+	}
+	err = write("samples.csv", append(header[:len(header):len(header)], "generation", "elapsed_s", "fitness"),
+		func(w *csv.Writer) {
+			for _, r := range runs {
+				for _, s := range r.progress.samples {
+					w.Write(append(runCols(r), fmt.Sprint(s.generation), fmt.Sprintf("%.3f", s.elapsed.Seconds()), fmt.Sprint(s.fitness)))
+				}
+			}
+		})
+	if err != nil {
+		return err
+	}
+	log.Println("results written to", dir)
+	return nil
+}
+
+const (
+	createSweepStr = `
+CREATE TABLE runs (
+	id INTEGER PRIMARY KEY,
+	config INTEGER NOT NULL,
+	seed INTEGER NOT NULL,
+	params TEXT NOT NULL,
+	status TEXT NOT NULL,
+	generations INTEGER,
+	fitness REAL,
+	duration REAL
+);
+CREATE TABLE samples (
+	run_id INTEGER NOT NULL REFERENCES runs(id),
+	generation INTEGER NOT NULL,
+	elapsed REAL NOT NULL,
+	fitness REAL NOT NULL
+);`
+	insertSweepRunStr    = `INSERT INTO runs(config, seed, params, status, generations, fitness, duration) values(?, ?, ?, ?, ?, ?, ?)`
+	insertSweepSampleStr = `INSERT INTO samples(run_id, generation, elapsed, fitness) values(?, ?, ?, ?)`
+)
+
+// writeSweepDB writes the results of a sweep in the SQLite database fn: the
+// runs table has a row per run, its swept parameters stored as a JSON object,
+// the samples table a row per recorded generation of each run.
+func writeSweepDB(fn string, params []sweepParam, runs []*sweepRun) error {
+	if err := os.MkdirAll(path.Dir(fn), 0755); err != nil {
+		return err
+	}
+	os.Remove(fn)
+	db, err := sql.Open("sqlite3", fn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if _, err = db.Exec(createSweepStr); err != nil {
+		return fmt.Errorf("can't create sweep database: %v", err)
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, r := range runs {
+		values := make(map[string]interface{})
+		for j, p := range params {
+			values[p.key] = r.values[j]
+		}
+		buf, err := json.Marshal(values)
+		if err != nil {
+			return err
+		}
+		var gens, fitness, duration interface{}
+		if r.res != nil && r.err == nil {
+			gens, fitness, duration = r.res.Generation, r.res.Fitness, r.duration.Seconds()
+		}
+		res, err := tx.Exec(insertSweepRunStr, r.config, r.opts.Seed, string(buf), r.status(), gens, fitness, duration)
+		if err != nil {
+			return fmt.Errorf("can't insert run: %v", err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		for _, s := range r.progress.samples {
+			if _, err = tx.Exec(insertSweepSampleStr, id, s.generation, s.elapsed.Seconds(), s.fitness); err != nil {
+				return fmt.Errorf("can't insert sample: %v", err)
+			}
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+	log.Println("results written to", fn)
+	return nil
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestParseSweepParam(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    sweepParam
+		wantErr bool
+	}{
+		{s: "Mutation.Mode=random,gaussian", want: sweepParam{key: "mutation.mode", values: []interface{}{"random", "gaussian"}}},
+		{s: "population.numindividuals=50,100", want: sweepParam{key: "population.numindividuals", values: []interface{}{json.Number("50"), json.Number("100")}}},
+		{s: "crossover.probability=0.5:1", want: sweepParam{key: "crossover.probability", min: 0.5, max: 1}},
+		{s: "crossover.probability=0.5:1:0.1", want: sweepParam{key: "crossover.probability", min: 0.5, max: 1, step: 0.1}},
+		{s: "crossover.probability", wantErr: true},
+		{s: "=1,2", wantErr: true},
+		{s: "seed=1,2", wantErr: true},
+		{s: "a=1:2:3:4", wantErr: true},
+		{s: "a=2:1", wantErr: true},
+		{s: "a=1:2:-1", wantErr: true},
+		{s: "a=x:2", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseSweepParam(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSweepParam(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseSweepParam(%q) = %+v, want %+v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestParamFlagsDuplicate(t *testing.T) {
+	var p paramFlags
+	if err := p.Set("a=1,2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Set("A=3"); err == nil {
+		t.Errorf("want error for a parameter swept twice")
+	}
+}
+
+func TestGrid(t *testing.T) {
+	tests := []struct {
+		p       sweepParam
+		want    []interface{}
+		wantErr bool
+	}{
+		{p: sweepParam{values: []interface{}{"a", "b"}}, want: []interface{}{"a", "b"}},
+		{p: sweepParam{min: 0.1, max: 0.5, step: 0.1}, want: []interface{}{0.1, 0.2, 0.3, 0.4, 0.5}},
+		{p: sweepParam{min: 1, max: 2, step: 0.4}, want: []interface{}{1.0, 1.4, 1.8}},
+		{p: sweepParam{min: 1, max: 1, step: 1}, want: []interface{}{1.0}},
+		{p: sweepParam{min: 0, max: 1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := tt.p.grid()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("grid of %+v: error = %v, wantErr %v", tt.p, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("grid of %+v = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+}
+
+func TestRandom(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	listed := sweepParam{values: []interface{}{"a", "b"}}
+	continuous := sweepParam{min: 0.5, max: 1}
+	stepped := sweepParam{min: 1, max: 2, step: 0.25}
+	for i := 0; i < 100; i++ {
+		if v := listed.random(rng); v != "a" && v != "b" {
+			t.Fatalf("got listed value %v", v)
+		}
+		if v := continuous.random(rng).(float64); v < 0.5 || v > 1 {
+			t.Fatalf("got value %v out of [0.5, 1]", v)
+		}
+		v := stepped.random(rng).(float64)
+		if v < 1 || v > 2 || v != roundValue(1+float64(int((v-1)/0.25))*0.25) {
+			t.Fatalf("got value %v, want a step of 0.25 in [1, 2]", v)
+		}
+	}
+}
+
+func TestRoundValue(t *testing.T) {
+	tests := []struct{ v, want float64 }{
+		{0.1 + 0.2, 0.3},
+		{0.7 * 3, 2.1},
+		{123456.789, 123456.789},
+		{1e-20 + 1, 1},
+	}
+	for _, tt := range tests {
+		if got := roundValue(tt.v); got != tt.want {
+			t.Errorf("roundValue(%v) = %v, want %v", tt.v, got, tt.want)
+		}
+	}
+}
+
+func TestSweepConfigs(t *testing.T) {
+	params := []sweepParam{
+		{key: "a", values: []interface{}{"x", "y"}},
+		{key: "b", min: 1, max: 3, step: 1},
+	}
+	got, err := sweepConfigs(params, searchGrid, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]interface{}{{"x", 1.0}, {"x", 2.0}, {"x", 3.0}, {"y", 1.0}, {"y", 2.0}, {"y", 3.0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("grid search: got %v, want %v", got, want)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	got, err = sweepConfigs(params, searchRandom, 4, rng)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("random search: got %v configurations, want 4", len(got))
+	}
+	for _, c := range got {
+		if len(c) != len(params) {
+			t.Errorf("random search: got configuration %v", c)
+		}
+	}
+
+	if _, err = sweepConfigs(params, searchRandom, 0, rng); err == nil {
+		t.Errorf("want error for 0 samples")
+	}
+	if _, err = sweepConfigs(params[1:], searchGrid, 0, nil); err != nil {
+		t.Errorf("grid search of a stepped range: %v", err)
+	}
+	if _, err = sweepConfigs([]sweepParam{{key: "c", min: 0, max: 1}}, searchGrid, 0, nil); err == nil {
+		t.Errorf("want error for grid search of a continuous range")
+	}
+	if _, err = sweepConfigs(params, "bayes", 1, rng); err == nil {
+		t.Errorf("want error for unknown search")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/aurelien-rainone/artificial/evolver"
+)
+
+// timelapseCmd implements the timelapse subcommand, that creates the time-lapse
+// animation of the evolution run of an existing output directory.
+func timelapseCmd(args []string) error {
+	fs := flag.NewFlagSet("timelapse", flag.ExitOnError)
+	out := fs.String("o", "timelapse.gif", "animation file")
+	delay := fs.Int("delay", 100, "delay between frames, in milliseconds")
+	scale := fs.Float64("scale", 1, "scale factor applied to the frames")
+	overlay := fs.Bool("overlay", false, "print generation number and fitness on frames")
+	maxFrames := fs.Int("max", 300, "maximum number of frames, frames being decimated evenly beyond (0 for no limit)")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s timelapse [flags] outdir\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	t, err := evolver.TimelapseFromDir(fs.Arg(0), evolver.TimelapseOptions{
+		Delay:     *delay,
+		Scale:     *scale,
+		Overlay:   *overlay,
+		MaxFrames: *maxFrames,
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("writing %d frames to %v\n", t.Len(), *out)
+	return t.Save(*out)
+}